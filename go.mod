@@ -1,5 +1,16 @@
 module github.com/autonubil/go-infosight
 
-go 1.13
+go 1.18
 
-require golang.org/x/oauth2 v0.0.0-20210220000619-9bb904979d93
+require (
+	golang.org/x/net v0.0.0-20200822124328-c89045814202
+	golang.org/x/oauth2 v0.0.0-20210220000619-9bb904979d93
+	golang.org/x/sync v0.1.0
+)
+
+require (
+	github.com/golang/protobuf v1.4.2 // indirect
+	golang.org/x/text v0.3.3 // indirect
+	google.golang.org/appengine v1.6.6 // indirect
+	google.golang.org/protobuf v1.25.0 // indirect
+)
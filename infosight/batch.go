@@ -0,0 +1,59 @@
+package infosight
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// ObjectSetQuery is a query of a BatchQuery
+type ObjectSetQuery struct {
+	// Name is the key of the result, defaults to ObjectSet
+	Name      string
+	ObjectSet string
+	Options   []RequestOption
+}
+
+// BatchQuery runs several queries and returns their results by name. InfoSight has no batch endpoint,
+// so the queries are sent concurrently (bounded by WithMaxConcurrentRequests). If a query fails,
+// the remaining ones are canceled and its error is returned.
+func (w *Wellness) BatchQuery(ctx context.Context, queries []ObjectSetQuery) (map[string]APIResponse, error) {
+	names := map[string]bool{}
+	for _, query := range queries {
+		name := query.Name
+		if name == "" {
+			name = query.ObjectSet
+		}
+		if names[name] {
+			return nil, fmt.Errorf("duplicate query name %q", name)
+		}
+		names[name] = true
+	}
+
+	var mu sync.Mutex
+	results := make(map[string]APIResponse, len(queries))
+	g, ctx := errgroup.WithContext(ctx)
+	for _, query := range queries {
+		query := query
+		g.Go(func() error {
+			r, err := w.GetObjectSetContext(ctx, query.ObjectSet, query.Options...)
+			if err != nil {
+				return err
+			}
+			name := query.Name
+			if name == "" {
+				name = query.ObjectSet
+			}
+			mu.Lock()
+			results[name] = *r
+			mu.Unlock()
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
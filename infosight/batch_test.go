@@ -0,0 +1,53 @@
+package infosight
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestBatchQuery(t *testing.T) {
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		severity := r.URL.Query().Get("condition.severity")
+		if severity == "unknown" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		issuesHandler(fmt.Sprintf(`[{"_id":"%s"}]`, severity))(w, r)
+	})
+	c, err := NewClient(ts.URL, WithMaxConcurrentRequests(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := c.Wellness.BatchQuery(context.Background(), []ObjectSetQuery{
+		{Name: "critical", ObjectSet: "issues", Options: []RequestOption{WithFilter("condition.severity", "critical")}},
+		{Name: "warning", ObjectSet: "issues", Options: []RequestOption{WithFilter("condition.severity", "warning")}},
+		{ObjectSet: "issues"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(results))
+	}
+	for name, expected := range map[string]string{"critical": "critical", "warning": "warning", "issues": ""} {
+		data := results[name].Data
+		if len(data) != 1 || data[0].(map[string]interface{})["_id"] != expected {
+			t.Errorf("unexpected result %s: %v", name, data)
+		}
+	}
+
+	_, err = c.Wellness.BatchQuery(context.Background(), []ObjectSetQuery{
+		{Name: "critical", ObjectSet: "issues", Options: []RequestOption{WithFilter("condition.severity", "critical")}},
+		{Name: "unknown", ObjectSet: "issues", Options: []RequestOption{WithFilter("condition.severity", "unknown")}},
+	})
+	if _, ok := err.(*FaultResponse); !ok {
+		t.Errorf("expected the fault of the failed query, got %v", err)
+	}
+
+	if _, err := c.Wellness.BatchQuery(context.Background(), []ObjectSetQuery{{ObjectSet: "issues"}, {ObjectSet: "issues"}}); err == nil {
+		t.Error("expected error for duplicate query names")
+	}
+}
@@ -0,0 +1,82 @@
+package infosight
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ErrChecksumMismatch is returned while reading a response body which does not match its checksum header
+var ErrChecksumMismatch = errors.New("infosight: response checksum mismatch")
+
+// WithVerifyChecksum verifies response bodies against their Content-MD5 or X-Checksum header.
+// X-Checksum may contain a hex or base64 encoded MD5 or SHA-256 digest. Responses without
+// checksum header are not verified.
+func WithVerifyChecksum(enabled bool) ClientOption {
+	return func(c *Client) error {
+		c.verifyChecksum = enabled
+		return nil
+	}
+}
+
+// verifyChecksum wraps the body of r to verify it against its checksum header once it is read completely
+func verifyChecksum(r *http.Response) error {
+	header := "Content-MD5"
+	value := r.Header.Get(header)
+	if value == "" {
+		header = "X-Checksum"
+		value = r.Header.Get(header)
+	}
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+
+	expected, err := hex.DecodeString(value)
+	if err != nil || header == "Content-MD5" {
+		expected, err = base64.StdEncoding.DecodeString(value)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s header %q", header, value)
+	}
+	var h hash.Hash
+	switch len(expected) {
+	case md5.Size:
+		h = md5.New()
+	case sha256.Size:
+		h = sha256.New()
+	default:
+		return fmt.Errorf("invalid %s header %q", header, value)
+	}
+
+	r.Body = &checksumBody{ReadCloser: r.Body, header: header, hash: h, expected: expected}
+	return nil
+}
+
+// checksumBody hashes a body while it is read and fails at its end if the digest does not match
+type checksumBody struct {
+	io.ReadCloser
+	header   string
+	hash     hash.Hash
+	expected []byte
+}
+
+// Read reads from the body, returning ErrChecksumMismatch instead of io.EOF if the digest does not match
+func (b *checksumBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.hash.Write(p[:n])
+	if err == io.EOF {
+		if actual := b.hash.Sum(nil); !bytes.Equal(actual, b.expected) {
+			return n, fmt.Errorf("%w: %s %x, body %x", ErrChecksumMismatch, b.header, b.expected, actual)
+		}
+	}
+	return n, err
+}
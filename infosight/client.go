@@ -2,21 +2,36 @@ package infosight
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
 	"os"
 	"strings"
+	"sync"
+	"time"
 
+	"golang.org/x/net/http2"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/clientcredentials"
+	"golang.org/x/sync/semaphore"
 )
 
 var (
-	defaultServer string = "https://infosight.hpe.com/apis/"
+	defaultServer      string        = "https://infosight.hpe.com/apis/"
+	defaultTokenPath   string        = "oauth/token"
+	defaultExpiryDelta time.Duration = 60 * time.Second
+
+	// tokenRetries is the number of retries of transient token endpoint failures
+	tokenRetries int = 3
+	// tokenRetryDelay is the delay before the first retry, it doubles with every attempt
+	tokenRetryDelay time.Duration = 500 * time.Millisecond
 )
 
 // ClientOption allows setting custom parameters during construction
@@ -38,7 +53,91 @@ func WithUserAgent(userAgent string) ClientOption {
 	}
 }
 
-// WithContext specifies the credentials for
+// RequestModifier modifies a request before it is sent, returning an error aborts the request
+type RequestModifier func(*http.Request) error
+
+// WithBeforeRequest calls fn with every request right before it is sent, e.g. to sign it or to add headers.
+// If fn returns an error the request is not sent. Multiple hooks are called in order.
+func WithBeforeRequest(fn func(*http.Request) error) ClientOption {
+	return WithRequestModifier(fn)
+}
+
+// WithRequestModifier appends fn to the chain of modifiers applied to every request right before it is sent,
+// e.g. to compose signing, header injection and tracing. Modifiers (including those of WithBeforeRequest) are
+// applied in registration order, the first error aborts the request and skips the remaining modifiers.
+func WithRequestModifier(fn RequestModifier) ClientOption {
+	return func(c *Client) error {
+		if fn == nil {
+			return errors.New("nil request modifier")
+		}
+		c.beforeRequest = append(c.beforeRequest, fn)
+		return nil
+	}
+}
+
+// WithHeader sends an additional header with every request, headers of the request context take precedence (see ContextWithHeaders)
+func WithHeader(name string, value string) ClientOption {
+	return func(c *Client) error {
+		if name == "" {
+			return errors.New("empty header name")
+		}
+		if c.headers == nil {
+			c.headers = map[string]string{}
+		}
+		c.headers[name] = value
+		return nil
+	}
+}
+
+// WithAPIKey authenticates with a static API key sent in header (e.g. X-API-Key) with every request instead of
+// an OAuth access token, no token is ever requested in this mode
+func WithAPIKey(header string, value string) ClientOption {
+	return func(c *Client) error {
+		if header == "" || strings.ContainsAny(header, " :\t\r\n") {
+			return fmt.Errorf("invalid API key header %q", header)
+		}
+		if value == "" {
+			return errors.New("empty API key")
+		}
+		c.apiKeyHeader = header
+		c.apiKey = value
+		return nil
+	}
+}
+
+// WithLocale requests localized messages by sending tag as Accept-Language header, e.g. WithLocale("de-DE")
+func WithLocale(tag string) ClientOption {
+	return func(c *Client) error {
+		if tag == "" {
+			return errors.New("empty locale")
+		}
+		c.locale = tag
+		return nil
+	}
+}
+
+// WithMediaTypeVersion selects version v of the response schema by requesting the media type
+// application/vnd.hpe.infosight.v<v>+json instead of application/json
+func WithMediaTypeVersion(v int) ClientOption {
+	return func(c *Client) error {
+		if v <= 0 {
+			return fmt.Errorf("invalid media type version %d", v)
+		}
+		c.accept = fmt.Sprintf("application/vnd.hpe.infosight.v%d+json", v)
+		return nil
+	}
+}
+
+// WithName tags all log output of the client with name, e.g. [ERROR][prod-eu]
+func WithName(name string) ClientOption {
+	return func(c *Client) error {
+		c.name = name
+		return nil
+	}
+}
+
+// WithContext specifies the context of the client, used for fetching tokens and calls without own context.
+// Calls with own context are governed by it, canceling the client context aborts them as well.
 func WithContext(ctx context.Context) ClientOption {
 	return func(c *Client) error {
 		c.ctx = ctx
@@ -55,10 +154,225 @@ func WithLogin(user string, password string) ClientOption {
 	}
 }
 
+// WithAuthStyle specifies how the client credentials are sent to the token endpoint,
+// by default the style is detected by probing the endpoint
+func WithAuthStyle(style oauth2.AuthStyle) ClientOption {
+	return func(c *Client) error {
+		c.authStyle = style
+		return nil
+	}
+}
+
+// WithScopes requests the given scopes from the token endpoint. By default no scope parameter is sent at all.
+func WithScopes(scopes ...string) ClientOption {
+	return func(c *Client) error {
+		for _, scope := range scopes {
+			if scope == "" {
+				return errors.New("empty scope")
+			}
+		}
+		c.scopes = scopes
+		return nil
+	}
+}
+
+// WithTokenPath sets the path of the token endpoint relative to the server url, defaults to oauth/token.
+// Use it for gateways mounting the token endpoint elsewhere on the same host, e.g. oauth2/token.
+func WithTokenPath(path string) ClientOption {
+	return func(c *Client) error {
+		path = strings.TrimPrefix(path, "/")
+		u, err := url.Parse(path)
+		if err != nil {
+			return fmt.Errorf("invalid token path %q: %w", path, err)
+		}
+		if path == "" || u.IsAbs() || u.Host != "" || u.RawQuery != "" || u.Fragment != "" || strings.HasPrefix(path, "/") {
+			return fmt.Errorf("invalid token path %q", path)
+		}
+		for _, segment := range strings.Split(path, "/") {
+			if segment == ".." {
+				return fmt.Errorf("invalid token path %q", path)
+			}
+		}
+		c.tokenPath = path
+		return nil
+	}
+}
+
+// WithExpiryDelta refreshes the access token d before it expires to tolerate clock skew, defaults to 60s
+func WithExpiryDelta(d time.Duration) ClientOption {
+	return func(c *Client) error {
+		if d < 0 {
+			return fmt.Errorf("invalid expiry delta %v", d)
+		}
+		c.expiryDelta = d
+		return nil
+	}
+}
+
+// WithDomain sets the default domain (product family) of all calls, defaults to DomainNimble. The domain is
+// normalized by ParseDomain, e.g. WithDomain(DomainThreePar) or WithDomain("3par"), strings read from a
+// configuration are converted with Domain(s).
+func WithDomain(domain Domain) ClientOption {
+	return func(c *Client) error {
+		d, err := ParseDomain(string(domain))
+		if err != nil {
+			return err
+		}
+		c.domain = d
+		return nil
+	}
+}
+
+// WithHTTP2 explicitly enables or disables HTTP/2, by default it is negotiated automatically
+func WithHTTP2(enabled bool) ClientOption {
+	return func(c *Client) error {
+		c.http2 = &enabled
+		return nil
+	}
+}
+
+// WithIgnoreFaultCodes treats faults with one of the given error codes (FaultDetail.ErrorCode) as empty result
+// instead of an error, e.g. for domains which may have no data
+func WithIgnoreFaultCodes(codes ...string) ClientOption {
+	return func(c *Client) error {
+		if c.ignoreFaultCodes == nil {
+			c.ignoreFaultCodes = map[string]bool{}
+		}
+		for _, code := range codes {
+			c.ignoreFaultCodes[code] = true
+		}
+		return nil
+	}
+}
+
+// WithResponseValidator replaces the default status check of responses (status codes above 399 are
+// returned as FaultResponse). If the validator returns an error, the response is discarded and the error returned.
+func WithResponseValidator(validator func(*http.Response) error) ClientOption {
+	return func(c *Client) error {
+		c.responseValidator = validator
+		return nil
+	}
+}
+
+// WithStatusHandler registers handler for responses with status code, it is consulted instead of the default
+// status check (status codes above 399 are returned as FaultResponse) and the retry rules. If handler returns retry,
+// the request is retried with the attempts and backoff of WithRetries, if it returns an error the response is
+// discarded and the error returned. Otherwise the response is returned as is, e.g. a 409 can be turned into a
+// custom error or a 202 retried until the result is ready.
+func WithStatusHandler(code int, handler func(*http.Response) (retry bool, err error)) ClientOption {
+	return func(c *Client) error {
+		if code < 100 || code > 599 {
+			return fmt.Errorf("invalid status code %d", code)
+		}
+		if handler == nil {
+			return fmt.Errorf("nil handler for status code %d", code)
+		}
+		if c.statusHandlers == nil {
+			c.statusHandlers = map[int]func(*http.Response) (bool, error){}
+		}
+		c.statusHandlers[code] = handler
+		return nil
+	}
+}
+
+// statusHandler returns the handler registered for the status code of r
+func (c *Client) statusHandler(r *http.Response) (func(*http.Response) (bool, error), bool) {
+	if r == nil {
+		return nil, false
+	}
+	handler, ok := c.statusHandlers[r.StatusCode]
+	return handler, ok
+}
+
+// WithClock replaces time.Now for computing relative time ranges like the window of GetRecentIssues, e.g. in tests
+func WithClock(now func() time.Time) ClientOption {
+	return func(c *Client) error {
+		if now == nil {
+			return errors.New("nil clock")
+		}
+		c.clock = now
+		return nil
+	}
+}
+
+// now returns the current time of the clock of the client
+func (c *Client) now() time.Time {
+	if c.clock != nil {
+		return c.clock()
+	}
+	return time.Now()
+}
+
+// WithMaxConcurrentRequests limits the number of concurrent requests to n. Further requests block until
+// the response body of a running request is closed or their context is done.
+func WithMaxConcurrentRequests(n int) ClientOption {
+	return func(c *Client) error {
+		if n <= 0 {
+			return fmt.Errorf("invalid number of concurrent requests %d", n)
+		}
+		c.slots = semaphore.NewWeighted(int64(n))
+		return nil
+	}
+}
+
+// ErrReadOnly is returned for requests which could change state if the client is read only, see WithReadOnly
+var ErrReadOnly = errors.New("infosight: client is read only")
+
+// WithReadOnly rejects all requests except GET, HEAD and OPTIONS with ErrReadOnly without sending them.
+// The wellness API has no write methods, this guards monitoring deployments against future ones and
+// request hooks changing the method.
+func WithReadOnly(readOnly bool) ClientOption {
+	return func(c *Client) error {
+		c.readOnly = readOnly
+		return nil
+	}
+}
+
+// WithMaxPageSize bounds the limit of all requests to n objects. Larger limits (and limit 0, which requests
+// the maximum page size of the server) are reduced to n and a warning is logged.
+func WithMaxPageSize(n int) ClientOption {
+	return func(c *Client) error {
+		if n <= 0 {
+			return fmt.Errorf("invalid maximum page size %d", n)
+		}
+		c.maxPageSize = n
+		return nil
+	}
+}
+
+// WithJSONUnmarshaler decodes responses with unmarshal instead of json.Unmarshal, e.g. with a faster
+// drop-in replacement like jsoniter's ConfigCompatibleWithStandardLibrary.Unmarshal. unmarshal must
+// not retain the passed data.
+func WithJSONUnmarshaler(unmarshal func(data []byte, v interface{}) error) ClientOption {
+	return func(c *Client) error {
+		c.unmarshal = unmarshal
+		return nil
+	}
+}
+
+// WithHTTPClient performs all API requests with doer instead of the built in OAuth client.
+// The doer is responsible for authentication, e.g. a ReplayDoer does not need any.
+func WithHTTPClient(doer HTTPRequestDoer) ClientOption {
+	return func(c *Client) error {
+		c.doer = doer
+		return nil
+	}
+}
+
+// WithRecorder records the exchanges of all API requests to w as JSON lines, to be replayed by a ReplayDoer.
+// Unlike a RecordingDoer passed to WithHTTPClient the client keeps authenticating with OAuth, the exchanges
+// are recorded below the authentication and token requests are not recorded. Clones record to the same writer.
+func WithRecorder(w io.Writer) ClientOption {
+	return func(c *Client) error {
+		c.recorder = NewRecordingDoer(nil, w)
+		return nil
+	}
+}
+
 // WithBaseURL overrides the baseURL.
 func WithBaseURL(baseURL string) ClientOption {
 	return func(c *Client) error {
-		newBaseURL, err := url.Parse(baseURL)
+		newBaseURL, err := parseServerURL(baseURL)
 		if err != nil {
 			return err
 		}
@@ -67,6 +381,21 @@ func WithBaseURL(baseURL string) ClientOption {
 	}
 }
 
+// parseServerURL parses a server url and ensures it is an absolute http or https url
+func parseServerURL(serverURL string) (*url.URL, error) {
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid server url %q: scheme must be http or https", serverURL)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid server url %q: missing host", serverURL)
+	}
+	return u, nil
+}
+
 // HTTPRequestDoer performs HTTP requests.
 //
 // The standard http.Client implements this interface.
@@ -85,24 +414,48 @@ type Fault struct {
 	Detail      *FaultDetail `json:"detail,omitempty"`
 }
 
+// maxFaultBody is the number of bytes of an error response kept in FaultResponse.RawBody
+const maxFaultBody = 64 << 10
+
 // FaultResponse is returned by InfoSight
 type FaultResponse struct {
 	Status     string
 	StatusCode int
 	Fault      *Fault `json:"fault,omitempty"`
+	// RawBody is the body of the response (truncated to 64 KiB), e.g. for error payloads not captured by Fault
+	RawBody []byte `json:"-"`
 }
 
 // NewFaultResponse create a new NewFaultResponse from an http response
 func NewFaultResponse(r *http.Response) (*FaultResponse, error) {
-	var faultResponse FaultResponse
-	decoder := json.NewDecoder(r.Body)
-	err := decoder.Decode(&faultResponse)
+	faultResponse, err := readFaultResponse(r)
 	if err != nil {
 		return nil, err
 	}
+	return faultResponse, nil
+}
+
+// readFaultResponse reads the body of an error response, it returns the fault even if the body is no JSON fault
+func readFaultResponse(r *http.Response) (*FaultResponse, error) {
+	faultResponse := &FaultResponse{}
+	raw, err := ioutil.ReadAll(io.LimitReader(r.Body, maxFaultBody))
+	if err == nil {
+		err = json.Unmarshal(raw, faultResponse)
+	}
 	faultResponse.Status = r.Status
 	faultResponse.StatusCode = r.StatusCode
-	return &faultResponse, nil
+	faultResponse.RawBody = raw
+	return faultResponse, err
+}
+
+// checkResponse is the default response validator, it returns a FaultResponse for status codes above 399
+func checkResponse(r *http.Response) error {
+	if r.StatusCode > 399 {
+		// if there is no JSON fault in the body, the fault only carries the status and raw body
+		fault, _ := readFaultResponse(r)
+		return fault
+	}
+	return nil
 }
 
 func (e *FaultResponse) Error() string {
@@ -112,15 +465,58 @@ func (e *FaultResponse) Error() string {
 	return e.Status
 }
 
+// ErrMaintenance matches (errors.Is) the FaultResponse InfoSight returns during maintenance
+var ErrMaintenance = errors.New("infosight: service under maintenance")
+
+// IsMaintenance reports whether the fault indicates maintenance of InfoSight, i.e. a 503 Service Unavailable
+// status with a fault string or error code mentioning maintenance
+func (e *FaultResponse) IsMaintenance() bool {
+	if e.StatusCode != http.StatusServiceUnavailable || e.Fault == nil {
+		return false
+	}
+	text := e.Fault.FaultString
+	if e.Fault.Detail != nil {
+		text += " " + e.Fault.Detail.ErrorCode
+	}
+	return strings.Contains(strings.ToLower(text), "maintenance")
+}
+
+// isIgnoredFault reports whether err is a fault whose error code is ignored, see WithIgnoreFaultCodes
+func (c *Client) isIgnoredFault(err error) bool {
+	var fault *FaultResponse
+	if len(c.ignoreFaultCodes) == 0 || !errors.As(err, &fault) || fault.Fault == nil || fault.Fault.Detail == nil {
+		return false
+	}
+	return c.ignoreFaultCodes[fault.Fault.Detail.ErrorCode]
+}
+
+// Is allows errors.Is(err, ErrMaintenance)
+func (e *FaultResponse) Is(target error) bool {
+	return target == ErrMaintenance && e.IsMaintenance()
+}
+
+// SessionInfo describes a polling session, see WithSession
+type SessionInfo struct {
+	SessionID               string            `json:"session_id,omitempty"`
+	SessionStartTime        Timestamp         `json:"sessionStartTime,omitempty"`
+	SessionLatestAccessTime Timestamp         `json:"sessionLatestAccessTime,omitempty"`
+	SessionTimeToLive       int64             `json:"sessionTimeToLive,omitempty"`
+	SessionStatus           string            `json:"sessionStatus,omitempty"`
+	SessionFilters          map[string]string `json:"sessionFilters,omitempty"`
+}
+
 // Status result status
 type Status struct {
-	Message string `json:"message,omitempty"`
+	Message     string       `json:"message,omitempty"`
+	SessionInfo *SessionInfo `json:"sessionInfo,omitempty"`
 }
 
 // PagingInfo request details
 type PagingInfo struct {
 	Skip  int `json:"skip,omitempty"`
 	Limit int `json:"limit,omitempty"`
+	// Total number of objects, if reported by the server
+	Total int `json:"total,omitempty"`
 }
 
 // FilterInfo filter details
@@ -136,12 +532,51 @@ type RequestInfo struct {
 	Paging *PagingInfo `json:"paging,omitempty"`
 	Filter *FilterInfo `json:"filter,omitempty"`
 	Sort   *Sorting    `json:"sort,omitempty"`
+	// FilterValues are the filters (and other query parameters) echoed by the server, see Filters
+	FilterValues map[string]string `json:"filters,omitempty"`
+}
+
+// EffectiveLimit returns the limit applied by the server, 0 if not reported
+func (r *RequestInfo) EffectiveLimit() int {
+	if r == nil || r.Paging == nil {
+		return 0
+	}
+	return r.Paging.Limit
+}
+
+// EffectiveSkip returns the number of skipped objects, 0 if not reported
+func (r *RequestInfo) EffectiveSkip() int {
+	if r == nil || r.Paging == nil {
+		return 0
+	}
+	return r.Paging.Skip
+}
+
+// Filters returns the filters applied by the server, an empty map if not reported
+func (r *RequestInfo) Filters() map[string]string {
+	filters := map[string]string{}
+	if r == nil {
+		return filters
+	}
+	if r.Filter != nil {
+		for field, value := range r.Filter.Query {
+			filters[field] = value
+		}
+	}
+	for field, value := range r.FilterValues {
+		filters[field] = value
+	}
+	return filters
 }
 
 // APIResponse returned on success
 type APIResponse struct {
 	Status  *Status      `json:"status,omitempty"`
 	Request *RequestInfo `json:"request,omitempty"`
+	// NextOffset and HasMore are sent by servers driving the pagination through the body instead of echoing
+	// the paging of the request, iterators prefer them over the computed offset. InfoSight does not send them.
+	NextOffset *int  `json:"nextOffset,omitempty"`
+	HasMore    *bool `json:"hasMore,omitempty"`
 
 	Data []interface{} `json:"data,omitempty"`
 }
@@ -151,19 +586,83 @@ type Client struct {
 	Server string
 
 	Wellness *Wellness
+	// Nimble and ThreePar are the wellness API scoped to the Nimble and 3PAR domains
+	Nimble   *DomainWellness
+	ThreePar *DomainWellness
 
 	// Doer for performing requests, typically a *http.Client with any
 	// customized settings, such as certificate chains.
 	innerClient HTTPRequestDoer
+	// doer replaces the OAuth client, see WithHTTPClient
+	doer HTTPRequestDoer
+	// recorder records the exchanges of API requests, see WithRecorder
+	recorder *RecordingDoer
+	// tokenClient talks to the token endpoint
+	tokenClient *http.Client
+	requests    requestTracker
+	// slots limits the number of concurrent requests, nil if unlimited
+	slots *semaphore.Weighted
+	// failoverServers are tried if the primary Server fails
+	failoverServers []string
+	// maxPageSize bounds the limit of requests, 0 if unbounded
+	maxPageSize int
+	// retries of transiently failed requests, see WithRetries
+	retries    int
+	retryDelay time.Duration
+	backoff    BackoffStrategy
+	// retryPredicate retries requests in addition to the default classification, see WithRetryPredicate
+	retryPredicate func(*http.Response, error) bool
+	// rateLimiter is waited for with rateLimitWeight tokens before every request, see WithRateLimiter
+	rateLimiter     RateLimiter
+	rateLimitWeight int
+
+	beforeRequest     []RequestModifier
+	responseValidator func(*http.Response) error
+	statusHandlers    map[int]func(*http.Response) (bool, error)
+	ignoreFaultCodes  map[string]bool
+	verifyChecksum    bool
+	readOnly          bool
+	coalescing        *coalescer
+	unmarshal         func([]byte, interface{}) error
+	traceRecorder     func(TraceRecord)
+	observer          Observer
+	clock             func() time.Time
+	harCapture        *harCapture
 
 	oauthConfig *clientcredentials.Config
 	ctx         context.Context
 	userAgent   string
+	locale      string
+	headers     map[string]string
+	accept      string
+	domain      Domain
+	name        string
+	tokenMu     sync.Mutex
 	token       *oauth2.Token
-	user        string
-	password    string
-	insecure    bool
-	trace       bool
+	// tokenOwner is the client whose token a clone shares, nil if the client owns its token
+	tokenOwner *Client
+	// tokenPath is the path of the token endpoint relative to Server
+	tokenPath string
+	// tokenCacheFile persists the token, see WithTokenCacheFile
+	tokenCacheFile   string
+	tokenCacheLoaded bool
+	// apiKeyHeader and apiKey replace the OAuth access token, see WithAPIKey
+	apiKeyHeader string
+	apiKey       string
+	user         string
+	password     string
+	authStyle    oauth2.AuthStyle
+	scopes       []string
+	expiryDelta  time.Duration
+	// autoRefreshBefore enables the background token refresh, see WithAutoRefreshBefore
+	autoRefreshBefore time.Duration
+	refreshStop       chan struct{}
+	refreshDone       chan struct{}
+	closeOnce         sync.Once
+	insecure          bool
+	http2             *bool
+	trace             bool
+	timing            bool
 }
 
 // NewClientFromEnvironment creates a new client from default environment variables
@@ -172,6 +671,20 @@ func NewClientFromEnvironment(opts ...ClientOption) (*Client, error) {
 	user := os.Getenv("INFOSIGHT_CLIENT_KEY")
 	password := os.Getenv("INFOSIGHT_CLIENT_SECRET")
 	opts = append(opts, WithLogin(user, password))
+	// explicit options take precedence over the environment
+	if env := os.Getenv("INFOSIGHT_DOMAIN"); env != "" {
+		domain, err := ParseDomain(env)
+		if err != nil {
+			return nil, fmt.Errorf("INFOSIGHT_DOMAIN: %w", err)
+		}
+		opts = append([]ClientOption{WithDomain(domain)}, opts...)
+	}
+
+	if baseURL != "" {
+		if _, err := parseServerURL(baseURL); err != nil {
+			return nil, fmt.Errorf("INFOSIGHT_URL: %w", err)
+		}
+	}
 
 	c, err := NewClient(baseURL, opts...)
 	if err != nil {
@@ -187,8 +700,12 @@ func NewClient(baseURL string, opts ...ClientOption) (*Client, error) {
 	baseURL = strings.TrimRight(baseURL, "/")
 
 	c := &Client{
-		Server:    baseURL,
-		userAgent: "go-infosight",
+		Server:      baseURL,
+		userAgent:   "go-infosight",
+		accept:      "application/json",
+		domain:      defaultDomain,
+		tokenPath:   defaultTokenPath,
+		expiryDelta: defaultExpiryDelta,
 	}
 
 	// mutate client and add all optional params
@@ -198,17 +715,147 @@ func NewClient(baseURL string, opts ...ClientOption) (*Client, error) {
 		}
 	}
 
+	if err := c.setup(); err != nil {
+		return nil, err
+	}
+	c.startAutoRefresh()
+	return c, nil
+}
+
+// Clone returns a new client with the configuration of c, modified by opts. Unless opts change the
+// credentials, scopes or server, the clone shares the access token of c instead of authenticating again.
+// The clone shares the concurrency limit of c, but tracks its own requests for Shutdown. The settings of
+// c.Wellness (Domain unless opts change it, Version, DiffKey, MaxItems, DefaultPaging and defaults) are kept.
+func (c *Client) Clone(opts ...ClientOption) (*Client, error) {
+	clone := &Client{
+		Server:            c.Server,
+		doer:              c.doer,
+		recorder:          c.recorder,
+		slots:             c.slots,
+		failoverServers:   append([]string{}, c.failoverServers...),
+		maxPageSize:       c.maxPageSize,
+		retries:           c.retries,
+		retryDelay:        c.retryDelay,
+		backoff:           c.backoff,
+		retryPredicate:    c.retryPredicate,
+		rateLimiter:       c.rateLimiter,
+		rateLimitWeight:   c.rateLimitWeight,
+		beforeRequest:     append([]RequestModifier{}, c.beforeRequest...),
+		responseValidator: c.responseValidator,
+		statusHandlers:    map[int]func(*http.Response) (bool, error){},
+		ignoreFaultCodes:  map[string]bool{},
+		verifyChecksum:    c.verifyChecksum,
+		readOnly:          c.readOnly,
+		coalescing:        c.coalescing,
+		unmarshal:         c.unmarshal,
+		traceRecorder:     c.traceRecorder,
+		observer:          c.observer,
+		clock:             c.clock,
+		harCapture:        c.harCapture,
+		ctx:               c.ctx,
+		userAgent:         c.userAgent,
+		locale:            c.locale,
+		headers:           map[string]string{},
+		accept:            c.accept,
+		domain:            c.domain,
+		name:              c.name,
+		apiKeyHeader:      c.apiKeyHeader,
+		apiKey:            c.apiKey,
+		user:              c.user,
+		password:          c.password,
+		authStyle:         c.authStyle,
+		scopes:            append([]string{}, c.scopes...),
+		expiryDelta:       c.expiryDelta,
+		autoRefreshBefore: c.autoRefreshBefore,
+		tokenPath:         c.tokenPath,
+		tokenCacheFile:    c.tokenCacheFile,
+		insecure:          c.insecure,
+		http2:             c.http2,
+		trace:             c.trace,
+		timing:            c.timing,
+	}
+	for name, value := range c.headers {
+		clone.headers[name] = value
+	}
+	for code, handler := range c.statusHandlers {
+		clone.statusHandlers[code] = handler
+	}
+	for code := range c.ignoreFaultCodes {
+		clone.ignoreFaultCodes[code] = true
+	}
+	for _, o := range opts {
+		if err := o(clone); err != nil {
+			return nil, err
+		}
+	}
+	if err := clone.setup(); err != nil {
+		return nil, err
+	}
+
+	if clone.Server == c.Server && clone.tokenPath == c.tokenPath && clone.user == c.user && clone.password == c.password &&
+		clone.authStyle == c.authStyle && strings.Join(clone.scopes, " ") == strings.Join(c.scopes, " ") {
+		clone.tokenOwner = c
+		if c.tokenOwner != nil {
+			clone.tokenOwner = c.tokenOwner
+		}
+	}
+	// the coalescing key does not cover the credentials, clones authenticating differently must not share responses
+	if clone.coalescing != nil && clone.coalescing == c.coalescing &&
+		(clone.tokenOwner == nil || clone.apiKeyHeader != c.apiKeyHeader || clone.apiKey != c.apiKey) {
+		clone.coalescing = &coalescer{}
+	}
+
+	if clone.domain == c.domain {
+		clone.Wellness.Domain = c.Wellness.Domain
+	}
+	clone.Wellness.Version = c.Wellness.Version
+	clone.Wellness.DiffKey = c.Wellness.DiffKey
+	clone.Wellness.MaxItems = c.Wellness.MaxItems
+	clone.Wellness.DefaultPaging = c.Wellness.DefaultPaging
+	c.Wellness.defaultsMu.RLock()
+	for objectSet, defaults := range c.Wellness.defaults {
+		clone.Wellness.SetDefaults(objectSet, defaults...)
+	}
+	for objectSet, fields := range c.Wellness.defaultFields {
+		clone.Wellness.SetDefaultFields(objectSet, fields...)
+	}
+	c.Wellness.defaultsMu.RUnlock()
+	clone.startAutoRefresh()
+	return clone, nil
+}
+
+// setup builds the transports of a client once its options are applied
+func (c *Client) setup() error {
 	if c.ctx == nil {
 		c.ctx = context.Background()
 	}
 
-	var transport http.RoundTripper = &BearerAuthTransport{http.DefaultTransport}
+	baseTransport := http.DefaultTransport
+	if c.http2 != nil {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		if *c.http2 {
+			if err := http2.ConfigureTransport(t); err != nil {
+				return err
+			}
+		} else {
+			// a non-nil, empty map disables HTTP/2
+			t.ForceAttemptHTTP2 = false
+			t.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+		}
+		baseTransport = t
+	}
+
+	var transport http.RoundTripper = &BearerAuthTransport{baseTransport}
 	// Override default HTTP client in ctx
-	c.ctx = context.WithValue(c.ctx, oauth2.HTTPClient, &http.Client{Transport: transport})
+	c.tokenClient = &http.Client{Transport: transport}
+	c.ctx = context.WithValue(c.ctx, oauth2.HTTPClient, c.tokenClient)
 
 	if c.Server == "" {
 		c.Server = defaultServer
 	}
+	if _, err := parseServerURL(c.Server); err != nil {
+		return err
+	}
 	// ensure the server URL always has a trailing slash
 	if !strings.HasSuffix(c.Server, "/") {
 		c.Server += "/"
@@ -217,73 +864,329 @@ func NewClient(baseURL string, opts ...ClientOption) (*Client, error) {
 	c.oauthConfig = &clientcredentials.Config{
 		ClientID:     c.user,
 		ClientSecret: c.password,
-		TokenURL:     c.Server + "oauth/token",
-		Scopes:       []string{""},
+		TokenURL:     c.Server + c.tokenPath,
+		Scopes:       c.scopes,
+		AuthStyle:    c.authStyle,
 	}
 
-	c.innerClient = c.oauthConfig.Client(c.ctx)
+	apiTransport := transport
+	if c.recorder != nil {
+		apiTransport = &BearerAuthTransport{recordingTransport{c.recorder.wrap(roundTripperDoer{baseTransport})}}
+	}
+	c.innerClient = c.doer
+	if c.innerClient != nil && c.recorder != nil {
+		c.innerClient = c.recorder.wrap(c.innerClient)
+	} else if c.innerClient == nil && c.apiKeyHeader != "" {
+		c.innerClient = &http.Client{Transport: apiTransport}
+	} else if c.innerClient == nil {
+		c.innerClient = &http.Client{Transport: &oauth2.Transport{Source: c, Base: apiTransport}}
+	}
 	c.Wellness = NewWellness(c)
-	return c, nil
+	c.Nimble = &DomainWellness{w: c.Wellness, Domain: DomainNimble}
+	c.ThreePar = &DomainWellness{w: c.Wellness, Domain: DomainThreePar}
+	return nil
+}
+
+// Token returns the cached access token and requests a new one if it is missing or expired.
+// It satisfies the oauth2.TokenSource interface.
+func (c *Client) Token() (*oauth2.Token, error) {
+	if c.apiKeyHeader != "" {
+		return nil, errors.New("the client authenticates with an API key")
+	}
+	if c.tokenOwner != nil {
+		return c.tokenOwner.Token()
+	}
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+
+	if c.tokenValid() {
+		return c.token, nil
+	}
+	if c.tokenCacheFile != "" && !c.tokenCacheLoaded {
+		c.tokenCacheLoaded = true
+		c.token = c.loadCachedToken()
+		if c.tokenValid() {
+			return c.token, nil
+		}
+	}
+	token, err := c.fetchToken()
+	if err != nil {
+		return nil, err
+	}
+	c.token = token
+	if c.tokenCacheFile != "" {
+		if err := c.saveCachedToken(token); err != nil {
+			c.Warnf("writing token cache %s: %v", c.tokenCacheFile, err)
+		}
+	}
+	return token, nil
+}
+
+// fetchToken requests a new token from the token endpoint and retries transient failures with exponential backoff
+func (c *Client) fetchToken() (*oauth2.Token, error) {
+	delay := tokenRetryDelay
+	for attempt := 1; ; attempt++ {
+		token, err := c.oauthConfig.Token(c.ctx)
+		if c.observer != nil {
+			c.observer.ObserveTokenRefresh(err)
+		}
+		if err == nil || attempt > tokenRetries || !isTransientTokenError(err) {
+			return token, err
+		}
+		c.Warnf("token request failed (attempt %d), retrying in %v: %v", attempt, delay, err)
+		select {
+		case <-c.ctx.Done():
+			return nil, err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// isTransientTokenError reports whether a token request might succeed when retried. The token endpoint
+// rejecting the request (e.g. 400 or 401) is permanent, 5xx and 429 responses and network errors are not.
+func isTransientTokenError(err error) bool {
+	var retrieveErr *oauth2.RetrieveError
+	if errors.As(err, &retrieveErr) {
+		if retrieveErr.Response == nil {
+			return false
+		}
+		return retrieveErr.Response.StatusCode >= 500 || retrieveErr.Response.StatusCode == http.StatusTooManyRequests
+	}
+	return true
+}
+
+// tokenValid reports whether the cached token is usable for at least the expiry delta
+func (c *Client) tokenValid() bool {
+	if c.token == nil || c.token.AccessToken == "" {
+		return false
+	}
+	if c.token.Expiry.IsZero() {
+		return true
+	}
+	return time.Now().Add(c.expiryDelta).Before(c.token.Expiry)
+}
+
+// InvalidateToken discards the cached access token, so the next call re-authenticates
+func (c *Client) InvalidateToken() {
+	if c.tokenOwner != nil {
+		c.tokenOwner.InvalidateToken()
+		return
+	}
+	c.tokenMu.Lock()
+	c.token = nil
+	c.tokenMu.Unlock()
+}
+
+// DebugToken requests a token from the token endpoint bypassing the oauth2 error handling and returns the
+// decoded JSON response and its status code. It is meant for troubleshooting authentication problems and
+// requires WithTrace(true).
+func (c *Client) DebugToken(ctx context.Context) (map[string]interface{}, int, error) {
+	if !c.trace {
+		return nil, 0, errors.New("DebugToken requires tracing to be enabled")
+	}
+
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if c.authStyle != oauth2.AuthStyleInHeader {
+		form.Set("client_id", c.user)
+		form.Set("client_secret", c.password)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", c.oauthConfig.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", c.userAgent)
+	if c.authStyle == oauth2.AuthStyleInHeader {
+		req.SetBasicAuth(url.QueryEscape(c.user), url.QueryEscape(c.password))
+	}
+
+	r, err := c.tokenClient.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer r.Body.Close()
+
+	var result map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&result); err != nil {
+		return nil, r.StatusCode, err
+	}
+	c.Tracef("token endpoint returned %s: %v", r.Status, redactTokenResponse(result))
+	return result, r.StatusCode, nil
+}
+
+// redactTokenResponse returns a copy of a token endpoint response with the tokens redacted for logging
+func redactTokenResponse(result map[string]interface{}) map[string]interface{} {
+	clean := make(map[string]interface{}, len(result))
+	for name, value := range result {
+		switch name {
+		case "access_token", "refresh_token", "id_token":
+			value = redacted
+		}
+		clean[name] = value
+	}
+	return clean
+}
+
+// logf logs a message with the level and the name of the client
+func (c *Client) logf(level string, format string, v ...interface{}) {
+	if c.name != "" {
+		log.Printf("[%s][%s] %s", level, c.name, fmt.Sprintf(format, v...))
+		return
+	}
+	log.Printf("[%s] %s", level, fmt.Sprintf(format, v...))
 }
 
 // Errorf logs errors
 func (c *Client) Errorf(format string, v ...interface{}) {
-	log.Printf("[ERROR] %s", fmt.Sprintf(format, v...))
+	c.logf("ERROR", format, v...)
 }
 
 // Warnf logs warnings
 func (c *Client) Warnf(format string, v ...interface{}) {
-	log.Printf("[WARN] %s", fmt.Sprintf(format, v...))
+	c.logf("WARN", format, v...)
 }
 
 // Debugf logs debug info
 func (c *Client) Debugf(format string, v ...interface{}) {
-	log.Printf("[DEBUG] %s", fmt.Sprintf(format, v...))
+	c.logf("DEBUG", format, v...)
 }
 
 // Tracef logs trace info
 func (c *Client) Tracef(format string, v ...interface{}) {
-	log.Printf("[TRACE] %s", fmt.Sprintf(format, v...))
+	c.logf("TRACE", format, v...)
 }
 
 // do execute and evaluate the request
 func (c *Client) do(req *http.Request) (*http.Response, error) {
-	// ensure we have a valid token
-	/*
-		if c.token == nil {
-			token, err := c.oauthConfig.Token(c.ctx)
-			if err != nil {
-				return nil, err
-			}
-			c.token = token
-		}
-
-		c.token.TokenType = "Bearer"
-	*/
-	req.WithContext(c.ctx)
 	// Headers for all request
 	req.Header.Set("User-Agent", c.userAgent)
-	req.Header.Set("Accept", "application/json")
+	if ua, ok := req.Context().Value(userAgentContextKey).(string); ok {
+		req.Header.Set("User-Agent", ua)
+	}
+	req.Header.Set("Accept", c.accept)
 	req.Header.Set("Content-Type", "application/json")
+	if c.locale != "" {
+		req.Header.Set("Accept-Language", c.locale)
+	}
+	for name, value := range c.headers {
+		req.Header.Set(name, value)
+	}
+	for name, value := range HeadersFromContext(req.Context()) {
+		req.Header.Set(name, value)
+	}
+	if c.apiKeyHeader != "" {
+		req.Header.Set(c.apiKeyHeader, c.apiKey)
+	}
+
+	// the context of the call governs the request, canceling the client context aborts it as well
+	ctx, stop := mergeContext(req.Context(), c.ctx)
+	ctx, done, err := c.requests.start(ctx)
+	if err != nil {
+		stop()
+		return nil, err
+	}
+	finishRequest := done
+	done = func() {
+		finishRequest()
+		stop()
+	}
+	req = req.WithContext(ctx)
+
+	if c.slots != nil {
+		if err := c.slots.Acquire(ctx, 1); err != nil {
+			done()
+			return nil, err
+		}
+		var once sync.Once
+		finish := done
+		done = func() {
+			once.Do(func() {
+				c.slots.Release(1)
+				finish()
+			})
+		}
+	}
+
+	for _, fn := range c.beforeRequest {
+		if err := fn(req); err != nil {
+			done()
+			return nil, err
+		}
+	}
 
-	r, e := c.innerClient.Do(req)
+	if c.readOnly {
+		switch req.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			done()
+			return nil, fmt.Errorf("%w: %s %s", ErrReadOnly, req.Method, req.URL.Redacted())
+		}
+	}
+
+	var r *http.Response
+	var e error
+	if c.coalescing != nil && req.Method == http.MethodGet {
+		r, e = c.coalesce(req)
+	} else {
+		req, r, e = c.exchange(req)
+	}
+	if r == nil {
+		done()
+		return r, e
+	}
+	r.Body = &trackedBody{r.Body, done}
+
+	validate := checkResponse
+	if c.responseValidator != nil {
+		validate = c.responseValidator
+	}
+	// responses with a status handler were checked by it
+	if _, handled := c.statusHandler(r); !handled {
+		if err := validate(r); err != nil {
+			r.Body.Close()
+			return nil, err
+		}
+	}
+	if c.verifyChecksum {
+		if err := verifyChecksum(r); err != nil {
+			r.Body.Close()
+			return nil, err
+		}
+	}
+	return r, e
+}
+
+// exchange sends the request (with retries and failover) and traces it. It returns the request which was sent last.
+func (c *Client) exchange(req *http.Request) (*http.Request, *http.Response, error) {
+	start := time.Now()
+	req, r, err := c.sendWithRetries(req)
+	if c.observer != nil {
+		c.observeRequest(start, req, r, err)
+	}
+	if c.traceRecorder != nil || c.harCapture != nil {
+		c.recordTrace(start, req, r, err)
+	}
 	if c.trace {
 		var reqStr = ""
-		dump, err := httputil.DumpRequestOut(req, true)
-		if err == nil {
+		dump, dumpErr := httputil.DumpRequestOut(req, true)
+		if dumpErr == nil {
 			reqStr = strings.ReplaceAll(strings.TrimRight(string(dump), "\r\n"), "\n", "\n                            ")
 		}
 		if r == nil {
 			dump = nil
-			err = nil
+			dumpErr = nil
 		} else {
-			dump, err = httputil.DumpResponse(r, true)
+			dump, dumpErr = httputil.DumpResponse(r, true)
 		}
-		if err == nil {
+		if dumpErr == nil {
 			c.Tracef("%s\n\n                            %s\n", reqStr, strings.ReplaceAll(strings.TrimRight(string(dump), "\r\n"), "\n", "\n                            "))
 		}
 	}
-	return r, e
+	if c.timing {
+		c.timeResponse(start, req, r, err)
+	}
+	return req, r, err
 }
 
 /* Workaround for wrong token type returned by InfoSight (BearerToken, but expects Bearer in auth header)
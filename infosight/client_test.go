@@ -0,0 +1,1174 @@
+package infosight
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// testServer is a stub InfoSight counting the issued tokens
+type testServer struct {
+	*httptest.Server
+	tokens int32
+	// expiresIn is the token lifetime in seconds, defaults to one hour
+	expiresIn int
+	// tokenFailures are the status codes returned by the first token requests
+	tokenFailures []int
+
+	mu           sync.Mutex
+	tokenRequest *http.Request
+}
+
+// newTestServer starts a stub InfoSight answering the token endpoint and passing everything else to handler
+func newTestServer(t *testing.T, handler http.HandlerFunc) *testServer {
+	ts := &testServer{expiresIn: 3600}
+	ts.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/oauth/token" {
+			r.ParseForm()
+			ts.mu.Lock()
+			ts.tokenRequest = r
+			ts.mu.Unlock()
+			n := atomic.AddInt32(&ts.tokens, 1)
+			w.Header().Set("Content-Type", "application/json")
+			if int(n) <= len(ts.tokenFailures) {
+				w.WriteHeader(ts.tokenFailures[n-1])
+				fmt.Fprint(w, `{"error":"unavailable"}`)
+				return
+			}
+			fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"BearerToken","expires_in":%d}`, n, ts.expiresIn)
+			return
+		}
+		handler(w, r)
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// Tokens returns the number of tokens issued so far
+func (ts *testServer) Tokens() int {
+	return int(atomic.LoadInt32(&ts.tokens))
+}
+
+// TokenRequest returns the last request received by the token endpoint
+func (ts *testServer) TokenRequest() *http.Request {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return ts.tokenRequest
+}
+
+// captureLog redirects the standard logger into a buffer for the duration of the test
+func captureLog(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	flags := log.Flags()
+	log.SetOutput(&buf)
+	log.SetFlags(0)
+	t.Cleanup(func() {
+		log.SetOutput(os.Stderr)
+		log.SetFlags(flags)
+	})
+	return &buf
+}
+
+// issuesHandler answers every request with the given list of issues
+func issuesHandler(data string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"data":%s,"status":{"message":"success"}}`, data)
+	}
+}
+
+func TestInvalidateToken(t *testing.T) {
+	var auth string
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		issuesHandler(`[]`)(w, r)
+	})
+
+	c, err := NewClient(ts.URL, WithLogin("key", "secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.Wellness.GetIssues(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if ts.Tokens() != 1 {
+		t.Errorf("expected the token to be reused, got %d token requests", ts.Tokens())
+	}
+	if auth != "Bearer token-1" {
+		t.Errorf("unexpected authorization header %q", auth)
+	}
+
+	c.InvalidateToken()
+	if _, err := c.Wellness.GetIssues(); err != nil {
+		t.Fatal(err)
+	}
+	if ts.Tokens() != 2 {
+		t.Errorf("expected a new token after invalidation, got %d token requests", ts.Tokens())
+	}
+	if auth != "Bearer token-2" {
+		t.Errorf("unexpected authorization header %q", auth)
+	}
+}
+
+func TestWithAuthStyle(t *testing.T) {
+	tests := []struct {
+		name   string
+		style  oauth2.AuthStyle
+		header bool
+	}{
+		{"header", oauth2.AuthStyleInHeader, true},
+		{"params", oauth2.AuthStyleInParams, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, issuesHandler(`[]`))
+			c, err := NewClient(ts.URL, WithLogin("key", "secret"), WithAuthStyle(tt.style))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := c.Token(); err != nil {
+				t.Fatal(err)
+			}
+
+			r := ts.TokenRequest()
+			user, password, basic := r.BasicAuth()
+			if basic != tt.header {
+				t.Errorf("expected basic auth %v, got %v", tt.header, basic)
+			}
+			if basic && (user != "key" || password != "secret") {
+				t.Errorf("unexpected basic auth credentials %s:%s", user, password)
+			}
+			if id := r.PostForm.Get("client_id"); tt.header == (id != "") {
+				t.Errorf("unexpected client_id %q in request body", id)
+			}
+			if !tt.header && r.PostForm.Get("client_secret") != "secret" {
+				t.Errorf("expected client_secret in request body")
+			}
+		})
+	}
+}
+
+func TestWithExpiryDelta(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   []ClientOption
+		tokens int
+	}{
+		{"default", nil, 2},
+		{"short delta", []ClientOption{WithExpiryDelta(10 * time.Second)}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, issuesHandler(`[]`))
+			ts.expiresIn = 30
+			c, err := NewClient(ts.URL, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 2; i++ {
+				if _, err := c.Wellness.GetIssues(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if ts.Tokens() != tt.tokens {
+				t.Errorf("expected %d token requests, got %d", tt.tokens, ts.Tokens())
+			}
+		})
+	}
+
+	if _, err := NewClient("", WithExpiryDelta(-time.Second)); err == nil {
+		t.Error("expected an error for a negative expiry delta")
+	}
+}
+
+func TestDebugToken(t *testing.T) {
+	ts := newTestServer(t, issuesHandler(`[]`))
+
+	c, err := NewClient(ts.URL, WithLogin("key", "secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := c.DebugToken(context.Background()); err == nil {
+		t.Error("expected DebugToken to require tracing")
+	}
+
+	c, err = NewClient(ts.URL, WithLogin("key", "secret"), WithTrace(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs := captureLog(t)
+	result, status, err := c.DebugToken(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(logs.String(), "token-1") || !strings.Contains(logs.String(), "access_token:xxxxx") {
+		t.Errorf("expected the access token to be redacted in the trace, got %q", logs.String())
+	}
+	if result["access_token"] != "token-1" {
+		t.Errorf("expected the access token in the result, got %v", result["access_token"])
+	}
+	if status != http.StatusOK {
+		t.Errorf("unexpected status %d", status)
+	}
+	if result["token_type"] != "BearerToken" {
+		t.Errorf("unexpected token type %v", result["token_type"])
+	}
+	if ts.TokenRequest().PostForm.Get("client_secret") != "secret" {
+		t.Errorf("expected client credentials in the request body")
+	}
+}
+
+func TestServerURLValidation(t *testing.T) {
+	tests := []struct {
+		url   string
+		valid bool
+	}{
+		{"", true},
+		{"https://infosight.hpe.com/apis/", true},
+		{"http://localhost:8080", true},
+		{"infosight.hpe.com", false},
+		{"infosight.hpe.com/apis", false},
+		{"localhost:8080", false},
+		{"ftp://infosight.hpe.com/apis/", false},
+		{"https://", false},
+		{"https:///apis", false},
+		{"://infosight.hpe.com", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			if _, err := NewClient(tt.url); (err == nil) != tt.valid {
+				t.Errorf("NewClient: expected valid=%v, got %v", tt.valid, err)
+			}
+			if tt.url != "" {
+				if _, err := NewClient("", WithBaseURL(tt.url)); (err == nil) != tt.valid {
+					t.Errorf("WithBaseURL: expected valid=%v, got %v", tt.valid, err)
+				}
+			}
+			t.Setenv("INFOSIGHT_URL", tt.url)
+			if _, err := NewClientFromEnvironment(); (err == nil) != tt.valid {
+				t.Errorf("NewClientFromEnvironment: expected valid=%v, got %v", tt.valid, err)
+			}
+		})
+	}
+}
+
+func TestWithName(t *testing.T) {
+	buf := captureLog(t)
+
+	c, err := NewClient("", WithName("prod-eu"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Errorf("failed %d times", 3)
+	c.Warnf("slow")
+
+	unnamed, err := NewClient("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unnamed.Debugf("plain")
+
+	expected := "[ERROR][prod-eu] failed 3 times\n[WARN][prod-eu] slow\n[DEBUG] plain\n"
+	if buf.String() != expected {
+		t.Errorf("expected log %q, got %q", expected, buf.String())
+	}
+}
+
+func TestWithLocale(t *testing.T) {
+	var languages []string
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		languages = append(languages, r.Header.Get("Accept-Language"))
+		issuesHandler(`[]`)(w, r)
+	})
+	for _, opts := range [][]ClientOption{nil, {WithLocale("de-DE")}} {
+		c, err := NewClient(ts.URL, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.Wellness.GetIssues(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !reflect.DeepEqual(languages, []string{"", "de-DE"}) {
+		t.Errorf("unexpected Accept-Language headers %q", languages)
+	}
+	if _, err := NewClient(ts.URL, WithLocale("")); err == nil {
+		t.Error("expected error for empty locale")
+	}
+}
+
+func TestWithMediaTypeVersion(t *testing.T) {
+	var accept []string
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		accept = append(accept, r.Header.Get("Accept"))
+		issuesHandler(`[]`)(w, r)
+	})
+	for _, opts := range [][]ClientOption{nil, {WithMediaTypeVersion(2)}} {
+		c, err := NewClient(ts.URL, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.Wellness.GetIssues(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected := []string{"application/json", "application/vnd.hpe.infosight.v2+json"}
+	if !reflect.DeepEqual(accept, expected) {
+		t.Errorf("expected Accept headers %q, got %q", expected, accept)
+	}
+	if _, err := NewClient(ts.URL, WithMediaTypeVersion(0)); err == nil {
+		t.Error("expected error for media type version 0")
+	}
+}
+
+func TestClone(t *testing.T) {
+	var requests []string
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Header.Get("User-Agent")+" "+r.URL.Query().Get("domain")+" "+r.Header.Get("Authorization"))
+		issuesHandler(`[]`)(w, r)
+	})
+	c, err := NewClient(ts.URL, WithLogin("key", "secret"), WithUserAgent("original"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clone, err := c.Clone(WithUserAgent("clone"), WithDomain("urn:3par"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := c.Clone(WithLogin("other", "secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, client := range []*Client{clone, c, other} {
+		if _, err := client.Wellness.GetIssues(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected := []string{
+		"clone urn:3par Bearer token-1",
+		"original urn:nimble Bearer token-1",
+		"original urn:nimble Bearer token-2",
+	}
+	if !reflect.DeepEqual(requests, expected) {
+		t.Errorf("expected requests %q, got %q", expected, requests)
+	}
+	if c.userAgent != "original" || c.Wellness.Domain != "urn:nimble" {
+		t.Errorf("clone modified the original client")
+	}
+}
+
+func TestCloneWellness(t *testing.T) {
+	c, err := NewClient("https://infosight.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Wellness.Domain = "urn:3par"
+	c.Wellness.Version = "v2"
+	c.Wellness.DiffKey = "uuid"
+	c.Wellness.MaxItems = 50
+	c.Wellness.DefaultPaging = PagingInfo{Limit: 25}
+
+	clone, err := c.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := clone.Wellness
+	if w.Domain != "urn:3par" || w.Version != "v2" || w.DiffKey != "uuid" || w.MaxItems != 50 || w.DefaultPaging.Limit != 25 {
+		t.Errorf("expected the settings of the wellness API to be kept, got %+v", w)
+	}
+
+	clone, err = c.Clone(WithDomain("urn:primera"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clone.Wellness.Domain != "urn:primera" || clone.Wellness.Version != "v2" {
+		t.Errorf("expected the domain of the options, got %q", clone.Wellness.Domain)
+	}
+}
+
+func TestTokenRetry(t *testing.T) {
+	delay := tokenRetryDelay
+	tokenRetryDelay = time.Millisecond
+	t.Cleanup(func() { tokenRetryDelay = delay })
+
+	tests := []struct {
+		name     string
+		failures []int
+		tokens   int
+		success  bool
+	}{
+		{"transient", []int{http.StatusServiceUnavailable, http.StatusBadGateway}, 3, true},
+		{"exhausted", []int{503, 503, 503, 503, 503}, 4, false},
+		{"unauthorized", []int{http.StatusUnauthorized}, 1, false},
+		{"bad request", []int{http.StatusBadRequest}, 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, issuesHandler(`[]`))
+			ts.tokenFailures = tt.failures
+			c, err := NewClient(ts.URL, WithAuthStyle(oauth2.AuthStyleInParams))
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = c.Token()
+			if (err == nil) != tt.success {
+				t.Errorf("expected success=%v, got %v", tt.success, err)
+			}
+			if ts.Tokens() != tt.tokens {
+				t.Errorf("expected %d token requests, got %d", tt.tokens, ts.Tokens())
+			}
+		})
+	}
+}
+
+func TestWithScopes(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  []ClientOption
+		scope []string
+	}{
+		{"no scopes", nil, nil},
+		{"one scope", []ClientOption{WithScopes("wellness")}, []string{"wellness"}},
+		{"multiple scopes", []ClientOption{WithScopes("wellness", "assets")}, []string{"wellness assets"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, issuesHandler(`[]`))
+			opts := append([]ClientOption{WithAuthStyle(oauth2.AuthStyleInParams)}, tt.opts...)
+			c, err := NewClient(ts.URL, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := c.Token(); err != nil {
+				t.Fatal(err)
+			}
+
+			r := ts.TokenRequest()
+			if scope := r.PostForm["scope"]; !reflect.DeepEqual(scope, tt.scope) {
+				t.Errorf("expected scope %q, got %q", tt.scope, scope)
+			}
+			if scope := r.URL.Query()["scope"]; scope != nil {
+				t.Errorf("expected no scope in the query, got %q", scope)
+			}
+		})
+	}
+
+	if _, err := NewClient("", WithScopes("")); err == nil {
+		t.Error("expected an error for an empty scope")
+	}
+}
+
+// transport returns the http transport underneath the bearer token workaround
+func transport(c *Client) *http.Transport {
+	return c.tokenClient.Transport.(*BearerAuthTransport).rt.(*http.Transport)
+}
+
+func TestWithHTTP2(t *testing.T) {
+	c, err := NewClient("", WithHTTP2(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := transport(c).TLSNextProto["h2"]; !ok {
+		t.Error("expected the transport to be configured for h2")
+	}
+	if protos := transport(c).TLSClientConfig.NextProtos; len(protos) == 0 || protos[0] != "h2" {
+		t.Errorf("expected h2 to be negotiated first, got %v", protos)
+	}
+
+	c, err = NewClient("", WithHTTP2(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next := transport(c).TLSNextProto; next == nil || len(next) != 0 {
+		t.Errorf("expected h2 to be disabled, got %v", next)
+	}
+
+	c, err = NewClient("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.tokenClient.Transport.(*BearerAuthTransport).rt != http.DefaultTransport {
+		t.Error("expected the default transport to be used")
+	}
+}
+
+func TestWithHTTP2Server(t *testing.T) {
+	var proto string
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proto = r.Proto
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token":"token","token_type":"BearerToken","expires_in":3600}`)
+	}))
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	t.Cleanup(ts.Close)
+
+	c, err := NewClient(ts.URL, WithHTTP2(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport(c).TLSClientConfig.RootCAs = ts.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs
+	if _, err := c.Token(); err != nil {
+		t.Fatal(err)
+	}
+	if proto != "HTTP/2.0" {
+		t.Errorf("expected HTTP/2.0, got %s", proto)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	started := make(chan struct{})
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(50 * time.Millisecond)
+		issuesHandler(`[]`)(w, r)
+	})
+	c, err := NewClient(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := make(chan error)
+	go func() {
+		_, err := c.Wellness.GetIssues()
+		result <- err
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Errorf("expected the in-flight request to finish, got %v", err)
+		}
+	default:
+		t.Error("Shutdown returned before the in-flight request finished")
+	}
+
+	if _, err := c.Wellness.GetIssues(); !errors.Is(err, ErrShutdown) {
+		t.Errorf("expected ErrShutdown, got %v", err)
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	})
+	c, err := NewClient(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := make(chan error)
+	go func() {
+		_, err := c.Wellness.GetIssues()
+		result <- err
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := c.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+	select {
+	case err := <-result:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected the in-flight request to be canceled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("the in-flight request was not canceled")
+	}
+}
+
+func TestWithContextCancelsCalls(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	})
+	clientCtx, cancelClient := context.WithCancel(context.Background())
+	c, err := NewClient(ts.URL, WithContext(clientCtx))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the call has its own context without deadline, canceling the client context still aborts it
+	result := make(chan error)
+	go func() {
+		_, err := c.Wellness.GetObjectSetContext(ContextWithDomain(context.Background(), "urn:3par"), "issues")
+		result <- err
+	}()
+	<-started
+	cancelClient()
+
+	select {
+	case err := <-result:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected the in-flight request to be canceled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("the in-flight request was not canceled")
+	}
+}
+
+func TestWithMaxConcurrentRequests(t *testing.T) {
+	var running, maxRunning int32
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		issuesHandler(`[]`)(w, r)
+	})
+	c, err := NewClient(ts.URL, WithMaxConcurrentRequests(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.Wellness.GetIssues(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := atomic.LoadInt32(&maxRunning); n != 2 {
+		t.Errorf("expected at most 2 concurrent requests, got %d", n)
+	}
+
+	// a waiting request gives up once its context is done
+	blocked, err := NewClient(ts.URL, WithMaxConcurrentRequests(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest("GET", ts.URL+"/wellness/v1/issues", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := blocked.do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Body.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := blocked.Wellness.GetObjectSetContext(ctx, "issues"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+
+	if _, err := NewClient(ts.URL, WithMaxConcurrentRequests(0)); err == nil {
+		t.Error("expected error for 0 concurrent requests")
+	}
+}
+
+func TestFaultResponse(t *testing.T) {
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("domain") == "urn:unknown" {
+			w.WriteHeader(http.StatusBadGateway)
+			fmt.Fprint(w, `<html>Bad Gateway</html>`)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"fault":{"faultstring":"Invalid or empty Nimble customer ID in access token","detail":{"errorcode":"invalid_customer"}}}`)
+	})
+
+	c, err := NewClient(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.Wellness.GetIssues()
+	var fault *FaultResponse
+	if !errors.As(err, &fault) {
+		t.Fatalf("expected a FaultResponse, got %v", err)
+	}
+	if fault.StatusCode != http.StatusBadRequest || fault.Fault.Detail.ErrorCode != "invalid_customer" {
+		t.Errorf("unexpected fault %+v", fault)
+	}
+
+	c, err = NewClient(ts.URL, WithDomain("urn:unknown"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.Wellness.GetIssues()
+	if !errors.As(err, &fault) || fault.StatusCode != http.StatusBadGateway || err.Error() != "502 Bad Gateway" {
+		t.Errorf("expected a status only fault, got %v", err)
+	}
+	if string(fault.RawBody) != `<html>Bad Gateway</html>` {
+		t.Errorf("expected the raw body of the fault, got %q", fault.RawBody)
+	}
+}
+
+func TestFaultResponseRawBodyLimit(t *testing.T) {
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, strings.Repeat("x", 2*maxFaultBody))
+	})
+	c, err := NewClient(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.Wellness.GetIssues()
+	var fault *FaultResponse
+	if !errors.As(err, &fault) || len(fault.RawBody) != maxFaultBody {
+		t.Errorf("expected the raw body to be truncated to %d bytes, got %v", maxFaultBody, err)
+	}
+}
+
+func TestRequestInfo(t *testing.T) {
+	var empty *RequestInfo
+	if empty.EffectiveLimit() != 0 || empty.EffectiveSkip() != 0 || len(empty.Filters()) != 0 {
+		t.Errorf("expected zero values for a missing request echo")
+	}
+	if (&RequestInfo{}).EffectiveLimit() != 0 {
+		t.Errorf("expected zero limit for missing paging")
+	}
+
+	var r APIResponse
+	err := json.Unmarshal([]byte(`{"request":{"filters":{"domain":"nimble","condition.severity":"critical"},"paging":{"skip":10,"limit":1}},"data":[]}`), &r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Request.EffectiveLimit() != 1 || r.Request.EffectiveSkip() != 10 {
+		t.Errorf("unexpected paging %+v", r.Request.Paging)
+	}
+	expected := map[string]string{"domain": "nimble", "condition.severity": "critical"}
+	if !reflect.DeepEqual(r.Request.Filters(), expected) {
+		t.Errorf("expected filters %v, got %v", expected, r.Request.Filters())
+	}
+}
+
+func TestWithIgnoreFaultCodes(t *testing.T) {
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, `{"fault":{"faultstring":"No data","detail":{"errorcode":"%s"}}}`, r.URL.Query().Get("domain"))
+	})
+	c, err := NewClient(ts.URL, WithDomain("no_data"), WithIgnoreFaultCodes("no_data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := c.Wellness.GetObjectSetContext(context.Background(), "issues")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Data == nil || len(r.Data) != 0 {
+		t.Errorf("expected an empty result, got %+v", r)
+	}
+
+	c, err = NewClient(ts.URL, WithDomain("invalid_domain"), WithIgnoreFaultCodes("no_data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Wellness.GetObjectSetContext(context.Background(), "issues"); err == nil {
+		t.Error("expected other fault codes to be returned as error")
+	}
+}
+
+func TestErrMaintenance(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		body        string
+		maintenance bool
+	}{
+		{"maintenance", http.StatusServiceUnavailable, `{"fault":{"faultstring":"Scheduled maintenance","detail":{"errorcode":"service.maintenance"}}}`, true},
+		{"overload", http.StatusServiceUnavailable, `{"fault":{"faultstring":"The Service is temporarily unavailable"}}`, false},
+		{"no json", http.StatusServiceUnavailable, `maintenance`, false},
+		{"other status", http.StatusInternalServerError, `{"fault":{"faultstring":"maintenance"}}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			})
+			c, err := NewClient(ts.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = c.Wellness.GetIssues()
+			if _, ok := err.(*FaultResponse); !ok {
+				t.Fatalf("expected a FaultResponse, got %v", err)
+			}
+			if errors.Is(err, ErrMaintenance) != tt.maintenance {
+				t.Errorf("expected errors.Is(err, ErrMaintenance) to be %v for %v", tt.maintenance, err)
+			}
+		})
+	}
+}
+
+func TestWithAPIKey(t *testing.T) {
+	var key, auth string
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		key = r.Header.Get("X-API-Key")
+		auth = r.Header.Get("Authorization")
+		issuesHandler(`[]`)(w, r)
+	})
+	c, err := NewClient(ts.URL, WithAPIKey("X-API-Key", "s3cret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Wellness.GetIssues(); err != nil {
+		t.Fatal(err)
+	}
+	if key != "s3cret" || auth != "" {
+		t.Errorf("expected only the API key header, got key %q and authorization %q", key, auth)
+	}
+	if ts.Tokens() != 0 {
+		t.Errorf("expected no token request, got %d", ts.Tokens())
+	}
+
+	for _, header := range []string{"", "X API Key", "X-API-Key:"} {
+		if _, err := NewClient(ts.URL, WithAPIKey(header, "s3cret")); err == nil {
+			t.Errorf("expected an error for header %q", header)
+		}
+	}
+	if _, err := NewClient(ts.URL, WithAPIKey("X-API-Key", "")); err == nil {
+		t.Error("expected an error for an empty key")
+	}
+}
+
+func TestWithTokenPath(t *testing.T) {
+	var tokenPath string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/apis/oauth2/token" {
+			tokenPath = r.URL.Path
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"access_token":"token","token_type":"BearerToken","expires_in":3600}`)
+			return
+		}
+		issuesHandler(`[]`)(w, r)
+	}))
+	defer ts.Close()
+
+	c, err := NewClient(ts.URL+"/apis", WithTokenPath("/oauth2/token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.oauthConfig.TokenURL != ts.URL+"/apis/oauth2/token" {
+		t.Errorf("unexpected token url %s", c.oauthConfig.TokenURL)
+	}
+	if _, err := c.Wellness.GetIssues(); err != nil {
+		t.Fatal(err)
+	}
+	if tokenPath != "/apis/oauth2/token" {
+		t.Errorf("expected the token to be requested from the custom path, got %q", tokenPath)
+	}
+
+	if c, err = NewClient(ts.URL); err != nil || c.oauthConfig.TokenURL != ts.URL+"/oauth/token" {
+		t.Errorf("expected the default token path, got %v (%v)", c.oauthConfig.TokenURL, err)
+	}
+	for _, invalid := range []string{"", "/", "https://other.example.com/oauth/token", "//other.example.com/token", "oauth/token?x=1", "../oauth/token"} {
+		if _, err := NewClient(ts.URL, WithTokenPath(invalid)); err == nil {
+			t.Errorf("expected an error for token path %q", invalid)
+		}
+	}
+}
+
+func TestWithBeforeRequest(t *testing.T) {
+	var signature string
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		signature = r.Header.Get("X-Signature")
+		issuesHandler(`[]`)(w, r)
+	})
+	sign := func(req *http.Request) error {
+		req.Header.Set("X-Signature", req.Method+" "+req.URL.Path)
+		return nil
+	}
+	c, err := NewClient(ts.URL, WithBeforeRequest(sign))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Wellness.GetIssues(); err != nil {
+		t.Fatal(err)
+	}
+	if signature != "GET /wellness/v1/issues" {
+		t.Errorf("expected the request to be signed, got %q", signature)
+	}
+
+	errSigning := errors.New("no signing key")
+	signature = ""
+	c, err = NewClient(ts.URL, WithBeforeRequest(func(*http.Request) error { return errSigning }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Wellness.GetIssues(); !errors.Is(err, errSigning) {
+		t.Errorf("expected the hook error, got %v", err)
+	}
+	if signature != "" {
+		t.Error("expected the request not to be sent")
+	}
+}
+
+func TestWithRequestModifier(t *testing.T) {
+	var header []string
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Values("X-Chain")
+		issuesHandler(`[]`)(w, r)
+	})
+	var applied []string
+	modifier := func(name string, err error) RequestModifier {
+		return func(req *http.Request) error {
+			applied = append(applied, name)
+			req.Header.Add("X-Chain", name)
+			return err
+		}
+	}
+
+	c, err := NewClient(ts.URL, WithRequestModifier(modifier("sign", nil)), WithBeforeRequest(modifier("header", nil)), WithRequestModifier(modifier("trace", nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Wellness.GetIssues(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(header, []string{"sign", "header", "trace"}) {
+		t.Errorf("expected the modifiers to be applied in order, got %v", header)
+	}
+
+	errAbort := errors.New("abort")
+	applied, header = nil, nil
+	c, err = NewClient(ts.URL, WithRequestModifier(modifier("sign", errAbort)), WithRequestModifier(modifier("trace", nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Wellness.GetIssues(); !errors.Is(err, errAbort) {
+		t.Errorf("expected the modifier error, got %v", err)
+	}
+	if !reflect.DeepEqual(applied, []string{"sign"}) || header != nil {
+		t.Errorf("expected the chain to stop at the first error without sending the request, applied %v", applied)
+	}
+
+	if _, err := NewClient(ts.URL, WithRequestModifier(nil)); err == nil {
+		t.Error("expected an error for a nil modifier")
+	}
+}
+
+func TestWithReadOnly(t *testing.T) {
+	var methods []string
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		issuesHandler(`[]`)(w, r)
+	})
+	c, err := NewClient(ts.URL, WithReadOnly(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Wellness.GetIssues(); err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest("POST", ts.URL+"/wellness/v1/issue/1/acknowledge", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.do(req); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("expected ErrReadOnly, got %v", err)
+	}
+	if !reflect.DeepEqual(methods, []string{"GET"}) {
+		t.Errorf("expected only the GET request to be sent, got %v", methods)
+	}
+}
+
+func TestWithResponseValidator(t *testing.T) {
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("domain") == "urn:accepted" {
+			w.WriteHeader(http.StatusAccepted)
+		} else {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		fmt.Fprint(w, `{"data":[],"status":{"message":"success"}}`)
+	})
+
+	errAccepted := errors.New("request accepted but not processed yet")
+	validator := func(r *http.Response) error {
+		if r.StatusCode == http.StatusAccepted {
+			return errAccepted
+		}
+		return nil
+	}
+
+	c, err := NewClient(ts.URL, WithResponseValidator(validator), WithDomain("urn:accepted"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Wellness.GetIssues(); err != errAccepted {
+		t.Errorf("expected the validator error, got %v", err)
+	}
+
+	// the validator replaces the default check, so 404 is accepted
+	c, err = NewClient(ts.URL, WithResponseValidator(validator))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Wellness.GetIssues(); err != nil {
+		t.Errorf("expected the validator to accept the response, got %v", err)
+	}
+}
+
+func TestWithStatusHandler(t *testing.T) {
+	var requests int32
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("domain") == "urn:conflict" {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		if atomic.AddInt32(&requests, 1) <= 2 {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		issuesHandler(issuesFixture)(w, r)
+	})
+
+	accepted := func(r *http.Response) (bool, error) {
+		return true, nil
+	}
+	errConflict := errors.New("conflicting request")
+	conflict := func(r *http.Response) (bool, error) {
+		return false, errConflict
+	}
+	c, err := NewClient(ts.URL, WithStatusHandler(http.StatusAccepted, accepted), WithStatusHandler(http.StatusConflict, conflict),
+		WithRetries(3, time.Millisecond), WithBackoffStrategy(ConstantBackoff{Delay: time.Millisecond}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, err := GetPage[Issue](c.Wellness, context.Background(), "issues")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Items) != 2 || atomic.LoadInt32(&requests) != 3 {
+		t.Errorf("expected the request to be retried until the result was ready, got %d issues after %d requests", len(page.Items), requests)
+	}
+
+	if _, err := c.Wellness.GetObjectSetContext(ContextWithDomain(context.Background(), "urn:conflict"), "issues"); !errors.Is(err, errConflict) {
+		t.Errorf("expected the handler error, got %v", err)
+	}
+
+	if _, err := NewClient(ts.URL, WithStatusHandler(42, accepted)); err == nil {
+		t.Error("expected an error for an invalid status code")
+	}
+}
+
+func TestWithVerifyChecksum(t *testing.T) {
+	body := `{"data":[],"status":{"message":"success"}}`
+	md5Sum := md5.Sum([]byte(body))
+	sha256Sum := sha256.Sum256([]byte(body))
+	otherSum := md5.Sum([]byte("truncated"))
+
+	tests := []struct {
+		name     string
+		header   string
+		value    string
+		expected error
+	}{
+		{"no header", "", "", nil},
+		{"content md5", "Content-MD5", base64.StdEncoding.EncodeToString(md5Sum[:]), nil},
+		{"hex sha256", "X-Checksum", hex.EncodeToString(sha256Sum[:]), nil},
+		{"base64 sha256", "X-Checksum", base64.StdEncoding.EncodeToString(sha256Sum[:]), nil},
+		{"mismatch", "X-Checksum", hex.EncodeToString(otherSum[:]), ErrChecksumMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				if tt.header != "" {
+					w.Header().Set(tt.header, tt.value)
+				}
+				fmt.Fprint(w, body)
+			})
+			c, err := NewClient(ts.URL, WithVerifyChecksum(true))
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = c.Wellness.GetIssues()
+			if !errors.Is(err, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestWithFailoverServers(t *testing.T) {
+	primary := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	var secondaryQuery url.Values
+	secondary := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		secondaryQuery = r.URL.Query()
+		issuesHandler(issuesFixture)(w, r)
+	})
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	buf := captureLog(t)
+	c, err := NewClient(primary.URL, WithFailoverServers(closed.URL, secondary.URL+"/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, err := c.Wellness.GetObjectSetContext(context.Background(), "issues", WithFilter("condition.severity", "critical"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Data) != 2 {
+		t.Errorf("expected the issues of the secondary server, got %v", page.Data)
+	}
+	if secondaryQuery.Get("condition.severity") != "critical" {
+		t.Errorf("expected the query to be preserved, got %v", secondaryQuery)
+	}
+	if !strings.Contains(buf.String(), "served by failover server "+secondary.URL+"/") {
+		t.Errorf("expected the failover to be logged, got %q", buf.String())
+	}
+
+	if _, err := NewClient(primary.URL, WithFailoverServers("secondary.example.com")); err == nil {
+		t.Error("expected an error for an invalid failover server")
+	}
+}
@@ -0,0 +1,141 @@
+package infosight
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// WithRequestCoalescing lets concurrent identical GET requests (same url and headers) share a single
+// HTTP call. Every caller receives its own copy of the response. Canceling a caller only abandons its
+// wait, the shared call is canceled once all callers are gone.
+func WithRequestCoalescing(enabled bool) ClientOption {
+	return func(c *Client) error {
+		c.coalescing = nil
+		if enabled {
+			c.coalescing = &coalescer{}
+		}
+		return nil
+	}
+}
+
+// coalescer tracks the callers waiting for the shared calls of identical requests
+type coalescer struct {
+	group singleflight.Group
+
+	mu    sync.Mutex
+	calls map[string]*coalescedCall
+	// attached is called once a caller waits for a shared call, tests hold back responses until all callers share them
+	attached func()
+}
+
+// coalescedCall is the context of a shared call, detached from the callers and canceled once none waits for it
+type coalescedCall struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	waiters int
+}
+
+// join registers a caller of the shared call of key, starting a new one with the values of ctx if there is none
+func (g *coalescer) join(key string, ctx context.Context) *coalescedCall {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	call := g.calls[key]
+	if call == nil {
+		call = &coalescedCall{}
+		call.ctx, call.cancel = context.WithCancel(detachedContext{ctx})
+		if g.calls == nil {
+			g.calls = map[string]*coalescedCall{}
+		}
+		g.calls[key] = call
+	}
+	call.waiters++
+	return call
+}
+
+// leave unregisters a caller and cancels the shared call once the last caller is gone. An abandoned call is
+// forgotten, so later identical requests start a new one instead of receiving its cancellation.
+func (g *coalescer) leave(key string, call *coalescedCall) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	call.waiters--
+	if call.waiters > 0 {
+		return
+	}
+	call.cancel()
+	if g.calls[key] == call {
+		delete(g.calls, key)
+		g.group.Forget(key)
+	}
+}
+
+// sharedResponse is a response read completely to be handed to all coalesced callers
+type sharedResponse struct {
+	r    *http.Response
+	body []byte
+}
+
+// coalesce performs the request unless an identical request is in flight and returns a copy of the shared response
+func (c *Client) coalesce(req *http.Request) (*http.Response, error) {
+	key := coalescingKey(req)
+	call := c.coalescing.join(key, req.Context())
+	defer c.coalescing.leave(key, call)
+
+	results := c.coalescing.group.DoChan(key, func() (interface{}, error) {
+		_, r, err := c.exchange(req.WithContext(call.ctx))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Body.Close()
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		return &sharedResponse{r: r, body: body}, nil
+	})
+	if c.coalescing.attached != nil {
+		c.coalescing.attached()
+	}
+	var result singleflight.Result
+	select {
+	case result = <-results:
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+	if result.Err != nil {
+		return nil, result.Err
+	}
+	if result.Shared {
+		c.Debugf("%s %s shared with a concurrent identical request", req.Method, req.URL.Redacted())
+	}
+
+	s := result.Val.(*sharedResponse)
+	r := *s.r
+	r.Header = s.r.Header.Clone()
+	r.Trailer = s.r.Trailer.Clone()
+	r.Body = ioutil.NopCloser(bytes.NewReader(s.body))
+	r.Request = req
+	return &r, nil
+}
+
+// coalescingKey identifies identical requests by method, url and headers
+func coalescingKey(req *http.Request) string {
+	names := make([]string, 0, len(req.Header))
+	for name := range req.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var key strings.Builder
+	key.WriteString(req.Method + " " + req.URL.String())
+	for _, name := range names {
+		key.WriteString("\n" + name + ": " + strings.Join(req.Header[name], ", "))
+	}
+	return key.String()
+}
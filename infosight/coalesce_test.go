@@ -0,0 +1,225 @@
+package infosight
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestWithRequestCoalescing(t *testing.T) {
+	var requests int32
+	release := make(chan struct{})
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		<-release
+		issuesHandler(issuesFixture)(w, r)
+	})
+	c, err := NewClient(ts.URL, WithRequestCoalescing(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	attached := make(chan struct{}, 10)
+	c.coalescing.attached = func() { attached <- struct{}{} }
+
+	const callers = 10
+	results := make([]*APIResponse, callers)
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			r, err := c.Wellness.GetObjectSetContext(context.Background(), "issues")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			results[i] = r
+		}(i)
+	}
+	// let all callers join the in-flight request
+	for i := 0; i < callers; i++ {
+		<-attached
+	}
+	close(release)
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("expected 1 request, got %d", n)
+	}
+	for i, r := range results {
+		if r == nil || len(r.Data) != 2 {
+			t.Fatalf("caller %d: unexpected result %v", i, r)
+		}
+	}
+	// every caller decoded its own copy
+	results[0].Data[0].(map[string]interface{})["_id"] = "modified"
+	if results[1].Data[0].(map[string]interface{})["_id"] == "modified" {
+		t.Error("expected independent results")
+	}
+
+	// different requests are not coalesced
+	c.coalescing.attached = nil
+	if _, err := c.Wellness.GetObjectSetContext(context.Background(), "issues", WithPaging(0, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Errorf("expected 2 requests, got %d", n)
+	}
+}
+
+func TestWithRequestCoalescingClones(t *testing.T) {
+	var requests int32
+	release := make(chan struct{})
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		<-release
+		issuesHandler(fmt.Sprintf(`[{"_id":%q}]`, r.Header.Get("Authorization")))(w, r)
+	})
+	c, err := NewClient(ts.URL, WithRequestCoalescing(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tenantA, err := c.Clone(WithLogin("tenant-a", "secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tenantB, err := c.Clone(WithLogin("tenant-b", "secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a clone sharing the token of tenant A still shares its requests
+	sibling, err := tenantA.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	attached := make(chan struct{}, 3)
+	tenantA.coalescing.attached = func() { attached <- struct{}{} }
+	tenantB.coalescing.attached = tenantA.coalescing.attached
+
+	clients := []*Client{tenantA, sibling, tenantB}
+	expected := make([]string, len(clients))
+	for i, client := range clients {
+		token, err := client.Token()
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected[i] = "Bearer " + token.AccessToken
+	}
+	if expected[0] == expected[2] {
+		t.Fatalf("expected the tenants to have different tokens, got %q", expected)
+	}
+
+	results := make([]string, len(clients))
+	var wg sync.WaitGroup
+	for i, client := range clients {
+		wg.Add(1)
+		go func(i int, client *Client) {
+			defer wg.Done()
+			r, err := client.Wellness.GetObjectSetContext(context.Background(), "issues")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			results[i] = r.Data[0].(map[string]interface{})["_id"].(string)
+		}(i, client)
+	}
+	for range clients {
+		<-attached
+	}
+	close(release)
+	wg.Wait()
+
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("expected every tenant to receive its own response %q, got %q", expected, results)
+	}
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Errorf("expected 2 requests, got %d", n)
+	}
+}
+
+func TestWithRequestCoalescingCanceledCaller(t *testing.T) {
+	var requests int32
+	release := make(chan struct{})
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		<-release
+		issuesHandler(issuesFixture)(w, r)
+	})
+	c, err := NewClient(ts.URL, WithRequestCoalescing(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	attached := make(chan struct{}, 2)
+	c.coalescing.attached = func() { attached <- struct{}{} }
+
+	// canceling the caller which started the shared call does not fail the others
+	leaderCtx, cancelLeader := context.WithCancel(context.Background())
+	leaderErr := make(chan error, 1)
+	go func() {
+		_, err := c.Wellness.GetObjectSetContext(leaderCtx, "issues")
+		leaderErr <- err
+	}()
+	<-attached
+	followerResult := make(chan *APIResponse, 1)
+	go func() {
+		r, err := c.Wellness.GetObjectSetContext(context.Background(), "issues")
+		if err != nil {
+			t.Error(err)
+		}
+		followerResult <- r
+	}()
+	<-attached
+	cancelLeader()
+	if err := <-leaderErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the leader to be canceled, got %v", err)
+	}
+	close(release)
+	if r := <-followerResult; r == nil || len(r.Data) != 2 {
+		t.Fatalf("expected the follower to receive the shared response, got %v", r)
+	}
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("expected 1 request, got %d", n)
+	}
+}
+
+func TestWithRequestCoalescingAllCallersCanceled(t *testing.T) {
+	received := make(chan struct{})
+	canceled := make(chan struct{})
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		close(received)
+		<-r.Context().Done()
+		close(canceled)
+	})
+	c, err := NewClient(ts.URL, WithRequestCoalescing(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	attached := make(chan struct{}, 2)
+	c.coalescing.attached = func() { attached <- struct{}{} }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := c.Wellness.GetObjectSetContext(ctx, "issues")
+			errs <- err
+		}()
+	}
+	<-attached
+	<-attached
+	<-received
+	cancel()
+	for i := 0; i < 2; i++ {
+		if err := <-errs; !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	}
+	// the shared call is canceled once no caller waits for it
+	<-canceled
+}
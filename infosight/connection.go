@@ -0,0 +1,50 @@
+package infosight
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+)
+
+// ConnectionError is returned if the server could not be reached, e.g. due to a DNS failure,
+// a refused connection or a timeout. It distinguishes network failures from faults returned by InfoSight.
+type ConnectionError struct {
+	// Host the request was sent to
+	Host string
+	Err  error
+}
+
+func (e *ConnectionError) Error() string {
+	return fmt.Sprintf("infosight: connecting to %s: %v", e.Host, e.Err)
+}
+
+// Unwrap returns the underlying network error
+func (e *ConnectionError) Unwrap() error {
+	return e.Err
+}
+
+// IsRetryable reports whether the request might succeed later, which is the case for all
+// connection errors except unknown hosts
+func (e *ConnectionError) IsRetryable() bool {
+	var dnsErr *net.DNSError
+	if errors.As(e.Err, &dnsErr) {
+		return !dnsErr.IsNotFound
+	}
+	return true
+}
+
+// connectionError wraps err in a ConnectionError if it is a connection level error of req
+func connectionError(req *http.Request, err error) error {
+	if err == nil || req.Context().Err() != nil {
+		return err
+	}
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	var urlErr *url.Error
+	if errors.As(err, &opErr) || errors.As(err, &dnsErr) || (errors.As(err, &urlErr) && urlErr.Timeout()) {
+		return &ConnectionError{Host: req.URL.Host, Err: err}
+	}
+	return err
+}
@@ -0,0 +1,35 @@
+package infosight
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+)
+
+func TestConnectionError(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	c, err := NewClient("http://"+addr, WithHTTPClient(http.DefaultClient))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.Wellness.GetIssues()
+	var connErr *ConnectionError
+	if !errors.As(err, &connErr) {
+		t.Fatalf("expected a ConnectionError, got %v", err)
+	}
+	if connErr.Host != addr || !connErr.IsRetryable() {
+		t.Errorf("unexpected connection error %v (retryable=%v)", connErr, connErr.IsRetryable())
+	}
+
+	notFound := &ConnectionError{Host: "infosight.invalid", Err: &net.DNSError{Err: "no such host", Name: "infosight.invalid", IsNotFound: true}}
+	if notFound.IsRetryable() {
+		t.Error("expected unknown hosts not to be retryable")
+	}
+}
@@ -0,0 +1,107 @@
+package infosight
+
+import (
+	"context"
+	"time"
+)
+
+// contextKey is the type of the context keys of this package
+type contextKey int
+
+const (
+	domainContextKey contextKey = iota
+	headersContextKey
+	// userAgentContextKey carries the user agent of a single request, see WithCallUserAgent
+	userAgentContextKey
+)
+
+// contextDomain is the domain stored by ContextWithDomain, err is reported by the requests made with it
+type contextDomain struct {
+	domain Domain
+	err    error
+}
+
+// ContextWithDomain returns a context scoping the wellness requests made with it to domain,
+// overriding the domain of the Wellness API, e.g. for per tenant requests. Like WithDomain the
+// domain is normalized by ParseDomain, requests made with an invalid domain fail with its error.
+func ContextWithDomain(ctx context.Context, domain Domain) context.Context {
+	d, err := ParseDomain(string(domain))
+	return context.WithValue(ctx, domainContextKey, contextDomain{d, err})
+}
+
+// DomainFromContext returns the domain stored by ContextWithDomain, false if there is none or it is invalid
+func DomainFromContext(ctx context.Context) (Domain, bool) {
+	domain, ok, err := domainFromContext(ctx)
+	return domain, ok && err == nil
+}
+
+// domainFromContext returns the domain stored by ContextWithDomain and the error of an invalid one
+func domainFromContext(ctx context.Context) (Domain, bool, error) {
+	d, ok := ctx.Value(domainContextKey).(contextDomain)
+	if !ok {
+		return "", false, nil
+	}
+	if d.err != nil {
+		return "", false, d.err
+	}
+	return d.domain, true, nil
+}
+
+// ContextWithHeaders returns a context adding headers to the requests made with it, e.g. for tracing.
+// They take precedence over the headers of the client, see WithHeader. Headers of an enclosing
+// ContextWithHeaders are kept unless overridden.
+func ContextWithHeaders(ctx context.Context, headers map[string]string) context.Context {
+	merged := map[string]string{}
+	for name, value := range HeadersFromContext(ctx) {
+		merged[name] = value
+	}
+	for name, value := range headers {
+		merged[name] = value
+	}
+	return context.WithValue(ctx, headersContextKey, merged)
+}
+
+// HeadersFromContext returns the headers stored by ContextWithHeaders
+func HeadersFromContext(ctx context.Context) map[string]string {
+	headers, _ := ctx.Value(headersContextKey).(map[string]string)
+	return headers
+}
+
+// detachedContext carries the values of a context without its deadline and cancellation
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
+
+func (d detachedContext) Value(key interface{}) interface{} {
+	return d.parent.Value(key)
+}
+
+// mergeContext returns a context carrying the values and deadline of ctx which is also canceled once parent
+// is done, e.g. the context of the client. stop releases the resources and must be called once the context
+// is no longer used.
+func mergeContext(ctx context.Context, parent context.Context) (context.Context, context.CancelFunc) {
+	merged, cancel := context.WithCancel(ctx)
+	if parent == nil || parent.Done() == nil || parent.Done() == ctx.Done() {
+		return merged, cancel
+	}
+	go func() {
+		select {
+		case <-parent.Done():
+			cancel()
+		case <-merged.Done():
+		}
+	}()
+	return merged, cancel
+}
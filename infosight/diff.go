@@ -0,0 +1,82 @@
+package infosight
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// defaultDiffKey identifies the objects of the issues object set
+const defaultDiffKey = "_id"
+
+// DiffObjectSet fetches the complete object set and compares it to a previous snapshot. Objects are matched
+// by the Wellness.DiffKey field. It returns the objects which are new, the objects which are gone and the
+// current version of the objects which differ from their previous version.
+func (w *Wellness) DiffObjectSet(ctx context.Context, objectSet string, previous APIResponse, opts ...RequestOption) (added, removed, changed []interface{}, err error) {
+	var current []interface{}
+	it := w.IterateObjectSet(ctx, objectSet, 0, opts...)
+	for it.Next() {
+		current = append(current, it.Page().Data...)
+	}
+	if err := it.Err(); err != nil {
+		return nil, nil, nil, err
+	}
+	return DiffObjects(w.DiffKey, previous.Data, current)
+}
+
+// DiffObjects compares two snapshots of an object set, objects are matched by the (dotted) key field
+func DiffObjects(key string, previous, current []interface{}) (added, removed, changed []interface{}, err error) {
+	before, err := indexObjects(key, previous)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	after, err := indexObjects(key, current)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	for _, obj := range current {
+		id, _ := objectKey(key, obj)
+		old, ok := before[id]
+		if !ok {
+			added = append(added, obj)
+		} else if !reflect.DeepEqual(old, obj) {
+			changed = append(changed, obj)
+		}
+	}
+	for _, obj := range previous {
+		id, _ := objectKey(key, obj)
+		if _, ok := after[id]; !ok {
+			removed = append(removed, obj)
+		}
+	}
+	return added, removed, changed, nil
+}
+
+// indexObjects maps objects by their key
+func indexObjects(key string, objects []interface{}) (map[string]interface{}, error) {
+	index := make(map[string]interface{}, len(objects))
+	for _, obj := range objects {
+		id, ok := objectKey(key, obj)
+		if !ok {
+			return nil, fmt.Errorf("object without key %q: %v", key, obj)
+		}
+		index[id] = obj
+	}
+	return index, nil
+}
+
+// objectKey looks up a dotted field of a decoded JSON object
+func objectKey(key string, obj interface{}) (string, bool) {
+	for _, name := range strings.Split(key, ".") {
+		fields, ok := obj.(map[string]interface{})
+		if !ok {
+			return "", false
+		}
+		if obj, ok = fields[name]; !ok || obj == nil {
+			return "", false
+		}
+	}
+	return fmt.Sprint(obj), true
+}
@@ -0,0 +1,52 @@
+package infosight
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Domain is the product family of the wellness API in its urn form, e.g. DomainNimble
+type Domain string
+
+const (
+	DomainNimble    Domain = "urn:nimble"
+	DomainThreePar  Domain = "urn:3par"
+	DomainPrimera   Domain = "urn:primera"
+	DomainStoreOnce Domain = "urn:storeonce"
+)
+
+// knownDomains are the domains recognized by ParseDomain by their name
+var knownDomains = map[string]Domain{
+	"nimble":    DomainNimble,
+	"3par":      DomainThreePar,
+	"threepar":  DomainThreePar,
+	"primera":   DomainPrimera,
+	"storeonce": DomainStoreOnce,
+}
+
+// String returns the urn of the domain as sent in the domain parameter of requests. Note that issues
+// report their domain by name (e.g. nimble), use ParseDomain to compare them.
+func (d Domain) String() string {
+	return string(d)
+}
+
+// ParseDomain parses the name or urn of a domain case insensitively, e.g. 3PAR or urn:3par.
+// Unknown domains are returned unchanged, so domains added by InfoSight can be used before they are known here.
+func ParseDomain(s string) (Domain, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", errors.New("empty domain")
+	}
+	if strings.ContainsAny(s, " \t\r\n") {
+		return "", fmt.Errorf("invalid domain %q", s)
+	}
+	name := strings.ToLower(s)
+	if strings.HasPrefix(name, "urn:") {
+		name = name[len("urn:"):]
+	}
+	if domain, ok := knownDomains[name]; ok {
+		return domain, nil
+	}
+	return Domain(s), nil
+}
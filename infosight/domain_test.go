@@ -0,0 +1,114 @@
+package infosight
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestParseDomain(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Domain
+		err      bool
+	}{
+		{"urn:nimble", DomainNimble, false},
+		{"Nimble", DomainNimble, false},
+		{"3PAR", DomainThreePar, false},
+		{"ThreePar", DomainThreePar, false},
+		{" URN:Primera ", DomainPrimera, false},
+		{"storeonce", DomainStoreOnce, false},
+		{"urn:simplivity", Domain("urn:simplivity"), false},
+		{"", "", true},
+		{"urn:3 par", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			domain, err := ParseDomain(tt.input)
+			if tt.err {
+				if err == nil {
+					t.Errorf("expected an error, got %q", domain)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if domain != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, domain)
+			}
+		})
+	}
+}
+
+func TestDomainString(t *testing.T) {
+	for domain, expected := range map[Domain]string{
+		DomainNimble:     "urn:nimble",
+		DomainThreePar:   "urn:3par",
+		DomainPrimera:    "urn:primera",
+		DomainStoreOnce:  "urn:storeonce",
+		Domain("custom"): "custom",
+	} {
+		if domain.String() != expected {
+			t.Errorf("expected %q, got %q", expected, domain.String())
+		}
+	}
+}
+
+func TestWithDomainParsed(t *testing.T) {
+	var domains []string
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		domains = append(domains, r.URL.Query().Get("domain"))
+		issuesHandler(`[{"_id":"1","domain":"nimble"}]`)(w, r)
+	})
+	// domains read from a configuration are plain strings
+	configured := "3PAR"
+	c, err := NewClient(ts.URL, WithDomain(Domain(configured)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Wellness.Domain != "urn:3par" {
+		t.Errorf("expected the parsed domain, got %q", c.Wellness.Domain)
+	}
+
+	tenant := "primera"
+	ctx := ContextWithDomain(context.Background(), Domain(tenant))
+	if domain, ok := DomainFromContext(ctx); !ok || domain != DomainPrimera {
+		t.Errorf("expected the parsed domain in the context, got %q", domain)
+	}
+	issues, err := c.Wellness.GetAllIssues(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Wellness.GetAllIssues(ContextWithDomain(context.Background(), DomainStoreOnce)); err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"urn:primera", "urn:storeonce"}; !reflect.DeepEqual(domains, expected) {
+		t.Errorf("expected domains %q, got %q", expected, domains)
+	}
+
+	// issues report their domain by name
+	if domain, err := ParseDomain(issues[0].Domain); err != nil || domain != DomainNimble {
+		t.Errorf("expected the domain of the issue to parse as %q, got %q (%v)", DomainNimble, domain, err)
+	}
+
+	if _, err := NewClient(ts.URL, WithDomain("")); err == nil {
+		t.Error("expected an error for an empty domain")
+	}
+	// invalid domains of a context are rejected like those of WithDomain instead of being sent
+	ctx = ContextWithDomain(context.Background(), "urn:3par ")
+	if _, ok := DomainFromContext(ctx); !ok {
+		t.Error("expected surrounding space to be trimmed")
+	}
+	ctx = ContextWithDomain(context.Background(), "nimble 3par")
+	if _, ok := DomainFromContext(ctx); ok {
+		t.Error("expected no domain for an invalid one")
+	}
+	if _, err := c.Wellness.GetAllIssues(ctx); err == nil {
+		t.Error("expected an error for an invalid domain of the context")
+	}
+	if len(domains) != 2 {
+		t.Errorf("expected no request with an invalid domain, got %q", domains)
+	}
+}
@@ -0,0 +1,107 @@
+package infosight
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Envelope is the full response of an object set with its objects kept raw until they are decoded, see GetEnvelope
+type Envelope struct {
+	Status  *Status      `json:"status,omitempty"`
+	Request *RequestInfo `json:"request,omitempty"`
+	// NextOffset and HasMore are only sent by servers driving the pagination through the body, see APIResponse
+	NextOffset *int  `json:"nextOffset,omitempty"`
+	HasMore    *bool `json:"hasMore,omitempty"`
+
+	Data []json.RawMessage `json:"data,omitempty"`
+
+	// unmarshal decodes the objects, see WithJSONUnmarshaler
+	unmarshal func([]byte, interface{}) error
+}
+
+// GetEnvelope fetches an object set and returns the full response. If the fault is ignored
+// (see WithIgnoreFaultCodes) an empty envelope is returned.
+func (w *Wellness) GetEnvelope(ctx context.Context, objectSet string, opts ...RequestOption) (*Envelope, error) {
+	var envelope Envelope
+	ignored, err := w.fetchInto(ctx, objectSet, &envelope, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if ignored {
+		return &Envelope{Data: []json.RawMessage{}, unmarshal: w.unmarshal}, nil
+	}
+	envelope.unmarshal = w.unmarshal
+	return &envelope, nil
+}
+
+// Message returns the status message of the response, e.g. Success
+func (e *Envelope) Message() string {
+	if e.Status == nil {
+		return ""
+	}
+	return e.Status.Message
+}
+
+// SessionID returns the id of the polling session created by WithSession, empty if there is none
+func (e *Envelope) SessionID() string {
+	if e.Status == nil || e.Status.SessionInfo == nil {
+		return ""
+	}
+	return e.Status.SessionInfo.SessionID
+}
+
+// Len returns the number of objects of the response
+func (e *Envelope) Len() int {
+	return len(e.Data)
+}
+
+// Total returns the total number of objects of the object set, 0 if not reported by the server
+func (e *Envelope) Total() int {
+	if e.Request == nil || e.Request.Paging == nil {
+		return 0
+	}
+	return e.Request.Paging.Total
+}
+
+// IsTruncated reports whether the object set holds more objects than returned up to this response, i.e. further
+// pages have to be fetched. It is false if the server reports neither more objects nor a total.
+func (e *Envelope) IsTruncated() bool {
+	if e.HasMore != nil {
+		return *e.HasMore
+	}
+	if total := e.Total(); total > 0 {
+		return e.Request.EffectiveSkip()+len(e.Data) < total
+	}
+	return false
+}
+
+// decode decodes data with the unmarshaler of the client the envelope was fetched with
+func (e *Envelope) decode(data []byte, v interface{}) error {
+	if e.unmarshal == nil {
+		return json.Unmarshal(data, v)
+	}
+	return e.unmarshal(data, v)
+}
+
+// Decode decodes the objects of the response into v, which must be a pointer to a slice
+func (e *Envelope) Decode(v interface{}) error {
+	raw, err := json.Marshal(e.Data)
+	if err != nil {
+		return err
+	}
+	return e.decode(raw, v)
+}
+
+// Issues decodes the objects of the response as issues
+func (e *Envelope) Issues() ([]Issue, error) {
+	issues := make([]Issue, 0, len(e.Data))
+	for i, raw := range e.Data {
+		var issue Issue
+		if err := e.decode(raw, &issue); err != nil {
+			return nil, fmt.Errorf("decoding object %d: %w", i, err)
+		}
+		issues = append(issues, issue)
+	}
+	return issues, nil
+}
@@ -0,0 +1,64 @@
+package infosight
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestGetEnvelope(t *testing.T) {
+	total := 5
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{
+			"status": {"message": "Success", "sessionInfo": {"session_id": "5f46f2fe-fb30-4f7f-82ce-ce50e941df70", "sessionStatus": "active"}},
+			"request": {"paging": {"skip": %s, "limit": 2, "total": %d}, "filters": {"condition.severity": "critical"}},
+			"data": %s
+		}`, r.URL.Query().Get("skip"), total, issuesFixture)
+	})
+	c, err := NewClient(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	envelope, err := c.Wellness.GetEnvelope(context.Background(), "issues", WithPaging(0, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if envelope.Message() != "Success" || envelope.SessionID() != "5f46f2fe-fb30-4f7f-82ce-ce50e941df70" {
+		t.Errorf("unexpected status %+v", envelope.Status)
+	}
+	if envelope.Len() != 2 || envelope.Total() != 5 || !envelope.IsTruncated() {
+		t.Errorf("expected 2 of 5 objects truncated, got len=%d total=%d truncated=%v", envelope.Len(), envelope.Total(), envelope.IsTruncated())
+	}
+	if envelope.Request.Filters()["condition.severity"] != "critical" {
+		t.Errorf("unexpected filters %v", envelope.Request.Filters())
+	}
+
+	issues, err := envelope.Issues()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) != 2 || issues[1].ID != "5d9eb55a28c7eb0001f472ec" || issues[1].Condition.Severity != "non-critical" {
+		t.Errorf("unexpected issues %+v", issues)
+	}
+	var ids []struct {
+		ID string `json:"_id"`
+	}
+	if err := envelope.Decode(&ids); err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 || ids[0].ID != "5d9eb55a28c7eb0001f472eb" {
+		t.Errorf("unexpected decoded ids %+v", ids)
+	}
+
+	// the last page is not truncated
+	envelope, err = c.Wellness.GetEnvelope(context.Background(), "issues", WithPaging(3, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if envelope.IsTruncated() {
+		t.Error("expected the last page not to be truncated")
+	}
+}
@@ -0,0 +1,38 @@
+package infosight
+
+import "context"
+
+// DomainWellness is the wellness API scoped to a domain (product family), see Client.Nimble and Client.ThreePar
+type DomainWellness struct {
+	w      *Wellness
+	Domain Domain
+}
+
+// context scopes ctx to the domain of the facade
+func (d *DomainWellness) context(ctx context.Context) context.Context {
+	return ContextWithDomain(ctx, d.Domain)
+}
+
+// GetObjectSetContext fetches a list of objects of the domain
+func (d *DomainWellness) GetObjectSetContext(ctx context.Context, objectSet string, opts ...RequestOption) (*APIResponse, error) {
+	return d.w.GetObjectSetContext(d.context(ctx), objectSet, opts...)
+}
+
+// IterateObjectSet returns an iterator fetching an object set of the domain page by page
+func (d *DomainWellness) IterateObjectSet(ctx context.Context, objectSet string, pageSize int, opts ...RequestOption) *Iterator {
+	return d.w.IterateObjectSet(d.context(ctx), objectSet, pageSize, opts...)
+}
+
+// GetIssues fetches a page of issues of the domain
+func (d *DomainWellness) GetIssues(ctx context.Context, opts ...RequestOption) ([]Issue, error) {
+	page, err := GetPage[Issue](d.w, d.context(ctx), "issues", opts...)
+	if err != nil {
+		return nil, err
+	}
+	return page.Items, nil
+}
+
+// GetAllIssues fetches all issues of the domain, see Wellness.GetAllIssues
+func (d *DomainWellness) GetAllIssues(ctx context.Context, opts ...RequestOption) ([]Issue, error) {
+	return d.w.GetAllIssues(d.context(ctx), opts...)
+}
@@ -0,0 +1,40 @@
+package infosight
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestDomainFacades(t *testing.T) {
+	var domains []string
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		domains = append(domains, r.URL.Query().Get("domain"))
+		issuesHandler(issuesFixture)(w, r)
+	})
+	c, err := NewClient(ts.URL, WithDomain("urn:primera"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	issues, err := c.Nimble.GetIssues(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) != 2 {
+		t.Errorf("expected 2 issues, got %d", len(issues))
+	}
+	if _, err := c.ThreePar.GetAllIssues(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Wellness.GetObjectSetContext(ctx, "issues"); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"urn:nimble", "urn:3par", "urn:primera"}
+	if !reflect.DeepEqual(domains, expected) {
+		t.Errorf("expected domains %v, got %v", expected, domains)
+	}
+}
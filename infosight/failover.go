@@ -0,0 +1,88 @@
+package infosight
+
+import (
+	"net/http"
+	"strings"
+)
+
+// WithFailoverServers adds servers which are tried in order if the primary server returns a connection error or a 5xx status
+func WithFailoverServers(urls ...string) ClientOption {
+	return func(c *Client) error {
+		for _, serverURL := range urls {
+			u, err := parseServerURL(serverURL)
+			if err != nil {
+				return err
+			}
+			server := u.String()
+			if !strings.HasSuffix(server, "/") {
+				server += "/"
+			}
+			c.failoverServers = append(c.failoverServers, server)
+		}
+		return nil
+	}
+}
+
+// send performs the request and fails over to the next server on connection errors and 5xx responses.
+// It returns the request which was sent last.
+func (c *Client) send(req *http.Request) (*http.Request, *http.Response, error) {
+	original := req
+	r, err := c.innerClient.Do(req)
+	for _, server := range c.failoverServers {
+		if !needsFailover(req, r, err) {
+			break
+		}
+		next, ok := c.rebase(original, server)
+		if !ok {
+			break
+		}
+
+		cause := ""
+		if r != nil {
+			cause = r.Status
+			r.Body.Close()
+		} else {
+			cause = err.Error()
+		}
+		c.Warnf("%s %s failed (%s), failing over to %s", req.Method, req.URL.Redacted(), cause, server)
+
+		req = next
+		r, err = c.innerClient.Do(req)
+		if !needsFailover(req, r, err) {
+			c.Debugf("%s %s served by failover server %s", req.Method, req.URL.Redacted(), server)
+		}
+	}
+	return req, r, err
+}
+
+// needsFailover reports whether a request failed in a way another server might not
+func needsFailover(req *http.Request, r *http.Response, err error) bool {
+	if err != nil {
+		// the caller gave up, no other server will help
+		return req.Context().Err() == nil
+	}
+	return r.StatusCode >= 500
+}
+
+// rebase returns a copy of a request to the primary server sent to server instead
+func (c *Client) rebase(req *http.Request, server string) (*http.Request, bool) {
+	path := strings.TrimPrefix(req.URL.String(), c.Server)
+	if path == req.URL.String() {
+		return nil, false
+	}
+	next := req.Clone(req.Context())
+	u, err := req.URL.Parse(server + path)
+	if err != nil {
+		return nil, false
+	}
+	next.URL = u
+	next.Host = ""
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, false
+		}
+		next.Body = body
+	}
+	return next, true
+}
@@ -0,0 +1,81 @@
+package infosight
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// timeFormat is the timestamp format used by InfoSight, e.g. 2020-05-29T02:58:53.643Z
+const timeFormat = "2006-01-02T15:04:05.000Z"
+
+// BuildFilter builds a filter from the fields of a struct tagged with `filter:"<field name>"`, e.g.
+//
+//	type IssueFilter struct {
+//		Severity string `filter:"condition.severity"`
+//		Status   string `filter:"status.value"`
+//	}
+//
+// Fields with a zero value are skipped, tagged fields must be exported. Supported field types are strings, numbers, booleans, time.Time and fmt.Stringer.
+func BuildFilter(v interface{}) (*FilterInfo, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil, fmt.Errorf("nil filter %T", v)
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("filter must be a struct, got %T", v)
+	}
+
+	filter := &FilterInfo{Query: map[string]string{}}
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		name, ok := rt.Field(i).Tag.Lookup("filter")
+		if !ok || name == "" || name == "-" {
+			continue
+		}
+		if !rt.Field(i).IsExported() {
+			return nil, fmt.Errorf("filter field %s is not exported", rt.Field(i).Name)
+		}
+		field := rv.Field(i)
+		if field.IsZero() {
+			continue
+		}
+		value, err := filterValue(field)
+		if err != nil {
+			return nil, fmt.Errorf("filter field %s: %w", rt.Field(i).Name, err)
+		}
+		filter.Query[name] = value
+	}
+	return filter, nil
+}
+
+// filterValue formats a struct field as query value
+func filterValue(v reflect.Value) (string, error) {
+	switch value := v.Interface().(type) {
+	case time.Time:
+		return value.UTC().Format(timeFormat), nil
+	case Timestamp:
+		return value.UTC().Format(timeFormat), nil
+	case fmt.Stringer:
+		return value.String(), nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64), nil
+	case reflect.Ptr:
+		return filterValue(v.Elem())
+	}
+	return "", fmt.Errorf("unsupported type %s", v.Type())
+}
@@ -0,0 +1,96 @@
+package infosight
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testIssueFilter struct {
+	Severity    string    `filter:"condition.severity"`
+	Status      string    `filter:"status.value"`
+	Asset       string    `filter:"asset.urn"`
+	Occurrences int       `filter:"status.occurrences"`
+	Since       time.Time `filter:"start_time"`
+	Session     *bool     `filter:"session_enabled"`
+	Ignored     string    `filter:"-"`
+	Untagged    string
+}
+
+func TestBuildFilter(t *testing.T) {
+	enabled := true
+	filter, err := BuildFilter(&testIssueFilter{
+		Severity: "critical",
+		Status:   "open",
+		Since:    time.Date(2020, 5, 29, 4, 58, 53, 643000000, time.FixedZone("CEST", 2*60*60)),
+		Session:  &enabled,
+		Ignored:  "ignored",
+		Untagged: "untagged",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"condition.severity": "critical",
+		"status.value":       "open",
+		"start_time":         "2020-05-29T02:58:53.643Z",
+		"session_enabled":    "true",
+	}
+	if !reflect.DeepEqual(filter.Query, expected) {
+		t.Errorf("expected %v, got %v", expected, filter.Query)
+	}
+
+	for _, invalid := range []interface{}{nil, "severity", (*testIssueFilter)(nil), struct {
+		Tags []string `filter:"tags"`
+	}{Tags: []string{"a"}}} {
+		if _, err := BuildFilter(invalid); err == nil {
+			t.Errorf("expected an error for %#v", invalid)
+		}
+	}
+}
+
+func TestBuildFilterUnexported(t *testing.T) {
+	filter, err := BuildFilter(struct {
+		Severity string `filter:"condition.severity"`
+		internal string
+	}{Severity: "critical", internal: "untagged"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(filter.Query, map[string]string{"condition.severity": "critical"}) {
+		t.Errorf("unexpected filter %v", filter.Query)
+	}
+
+	_, err = BuildFilter(struct {
+		severity string `filter:"condition.severity"`
+	}{severity: "critical"})
+	if err == nil || err.Error() != "filter field severity is not exported" {
+		t.Errorf("expected an error for the unexported field, got %v", err)
+	}
+}
+
+func TestWithFilterInfo(t *testing.T) {
+	var rawQuery string
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		rawQuery = r.URL.RawQuery
+		issuesHandler(`[]`)(w, r)
+	})
+	c, err := NewClient(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filter, err := BuildFilter(testIssueFilter{Severity: "critical", Occurrences: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Wellness.GetObjectSetContext(context.Background(), "issues", WithFilterInfo(filter)); err != nil {
+		t.Fatal(err)
+	}
+	expected := "condition.severity=critical&domain=urn%3Animble&status.occurrences=2"
+	if rawQuery != expected {
+		t.Errorf("expected query %q, got %q", expected, rawQuery)
+	}
+}
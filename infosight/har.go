@@ -0,0 +1,187 @@
+package infosight
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+)
+
+// harCapture keeps the trace records of the last exchanges for WriteHAR
+type harCapture struct {
+	mu      sync.Mutex
+	max     int
+	records []TraceRecord
+}
+
+func (h *harCapture) add(record TraceRecord) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.records = append(h.records, record)
+	if len(h.records) > h.max {
+		h.records = append([]TraceRecord{}, h.records[len(h.records)-h.max:]...)
+	}
+}
+
+// WithHARCapture keeps the trace records (see WithTraceRecorder) of the last max exchanges in memory,
+// WriteHAR writes them as HAR file. Clones share the captured exchanges.
+func WithHARCapture(max int) ClientOption {
+	return func(c *Client) error {
+		if max < 1 {
+			return errors.New("HAR capture needs to keep at least one exchange")
+		}
+		c.harCapture = &harCapture{max: max}
+		return nil
+	}
+}
+
+type harLog struct {
+	Log harContent `json:"log"`
+}
+
+type harContent struct {
+	Version string     `json:"version"`
+	Creator harCreator `json:"creator"`
+	Entries []harEntry `json:"entries"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            int64       `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+	Error           string      `json:"_error,omitempty"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harBody        `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harBody struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harTimings struct {
+	Send    int64 `json:"send"`
+	Wait    int64 `json:"wait"`
+	Receive int64 `json:"receive"`
+}
+
+// harHeaders converts h into HAR name value pairs sorted by name
+func harHeaders(h http.Header) []harNameValue {
+	pairs := []harNameValue{}
+	for name, values := range h {
+		for _, value := range values {
+			pairs = append(pairs, harNameValue{Name: name, Value: value})
+		}
+	}
+	sort.SliceStable(pairs, func(i, j int) bool { return pairs[i].Name < pairs[j].Name })
+	return pairs
+}
+
+// harEntryOf converts a trace record into a HAR entry, the record is already redacted
+func harEntryOf(record TraceRecord) harEntry {
+	entry := harEntry{
+		StartedDateTime: record.Time.UTC().Format("2006-01-02T15:04:05.000Z"),
+		Time:            record.DurationMs,
+		Request: harRequest{
+			Method:      record.Method,
+			URL:         record.URL,
+			HTTPVersion: "HTTP/1.1",
+			Cookies:     []harNameValue{},
+			Headers:     harHeaders(record.RequestHeader),
+			QueryString: []harNameValue{},
+			HeadersSize: -1,
+			BodySize:    len(record.RequestBody),
+		},
+		Response: harResponse{
+			Status:      record.Status,
+			StatusText:  http.StatusText(record.Status),
+			HTTPVersion: "HTTP/1.1",
+			Cookies:     []harNameValue{},
+			Headers:     harHeaders(record.ResponseHeader),
+			Content: harBody{
+				Size:     len(record.ResponseBody),
+				MimeType: record.ResponseHeader.Get("Content-Type"),
+				Text:     record.ResponseBody,
+			},
+			HeadersSize: -1,
+			BodySize:    len(record.ResponseBody),
+		},
+		// only the total duration is measured, it is reported as waiting time
+		Timings: harTimings{Wait: record.DurationMs},
+		Error:   record.Error,
+	}
+	if u, err := url.Parse(record.URL); err == nil {
+		entry.Request.QueryString = harHeaders(http.Header(u.Query()))
+	}
+	if record.RequestBody != "" {
+		entry.Request.PostData = &harPostData{MimeType: record.RequestHeader.Get("Content-Type"), Text: record.RequestBody}
+	}
+	return entry
+}
+
+// WriteHAR writes the exchanges kept by WithHARCapture as HAR 1.2 file to w, e.g. to load them into the network panel
+// of browser devtools. Credentials are redacted like in trace records.
+func (c *Client) WriteHAR(w io.Writer) error {
+	if c.harCapture == nil {
+		return errors.New("no exchanges captured, see WithHARCapture")
+	}
+	c.harCapture.mu.Lock()
+	records := append([]TraceRecord{}, c.harCapture.records...)
+	c.harCapture.mu.Unlock()
+
+	har := harLog{Log: harContent{
+		Version: "1.2",
+		Creator: harCreator{Name: "go-infosight", Version: "1.0"},
+		Entries: make([]harEntry, 0, len(records)),
+	}}
+	for _, record := range records {
+		har.Log.Entries = append(har.Log.Entries, harEntryOf(record))
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(har)
+}
@@ -0,0 +1,84 @@
+package infosight
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestWriteHAR(t *testing.T) {
+	ts := newTestServer(t, issuesHandler(issuesFixture))
+	c, err := NewClient(ts.URL, WithHARCapture(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := c.Wellness.GetObjectSetContext(context.Background(), "issues", WithQueryParam("api_key", "s3cret")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := c.WriteHAR(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "s3cret") {
+		t.Error("expected the api key to be redacted")
+	}
+	var har struct {
+		Log struct {
+			Version string `json:"version"`
+			Entries []struct {
+				StartedDateTime string `json:"startedDateTime"`
+				Request         struct {
+					Method      string `json:"method"`
+					URL         string `json:"url"`
+					QueryString []struct {
+						Name  string `json:"name"`
+						Value string `json:"value"`
+					} `json:"queryString"`
+				} `json:"request"`
+				Response struct {
+					Status  int `json:"status"`
+					Content struct {
+						MimeType string `json:"mimeType"`
+						Text     string `json:"text"`
+					} `json:"content"`
+				} `json:"response"`
+				Timings *struct {
+					Wait *int64 `json:"wait"`
+				} `json:"timings"`
+			} `json:"entries"`
+		} `json:"log"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &har); err != nil {
+		t.Fatalf("invalid HAR JSON: %v", err)
+	}
+	if har.Log.Version != "1.2" || len(har.Log.Entries) != 2 {
+		t.Fatalf("expected a HAR 1.2 log with the last 2 exchanges, got version %q with %d entries", har.Log.Version, len(har.Log.Entries))
+	}
+	entry := har.Log.Entries[0]
+	if entry.Request.Method != "GET" || !strings.HasPrefix(entry.Request.URL, ts.URL+"/wellness/v1/issues?") || entry.StartedDateTime == "" {
+		t.Errorf("unexpected request %+v", entry.Request)
+	}
+	if len(entry.Request.QueryString) == 0 {
+		t.Error("expected the query parameters to be listed")
+	}
+	if entry.Response.Status != http.StatusOK || entry.Response.Content.MimeType != "application/json" || !strings.Contains(entry.Response.Content.Text, "5d9eb55a28c7eb0001f472ec") {
+		t.Errorf("unexpected response %+v", entry.Response)
+	}
+	if entry.Timings == nil || entry.Timings.Wait == nil {
+		t.Error("expected timings")
+	}
+
+	c, err = NewClient(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.WriteHAR(&buf); err == nil {
+		t.Error("expected an error without HAR capture")
+	}
+}
@@ -0,0 +1,192 @@
+package infosight
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// IssueCondition describes the condition which raised an issue
+type IssueCondition struct {
+	URN      string `json:"urn,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Severity string `json:"severity,omitempty"`
+	Category string `json:"category,omitempty"`
+}
+
+// IssueObject references an object (array, volume, ...) or asset an issue applies to
+type IssueObject struct {
+	URN  string `json:"urn,omitempty"`
+	Name string `json:"name,omitempty"`
+}
+
+// IssueBody detailed description of an issue
+type IssueBody struct {
+	Type      string `json:"type,omitempty"`
+	MediaType string `json:"mediatype,omitempty"`
+	Content   string `json:"content,omitempty"`
+}
+
+// IssueStatus current state of an issue
+type IssueStatus struct {
+	Value            string    `json:"value,omitempty"`
+	Timestamp        Timestamp `json:"timestamp,omitempty"`
+	User             string    `json:"user,omitempty"`
+	InitialOccurence Timestamp `json:"initialoccurence,omitempty"`
+	LatestOccurence  Timestamp `json:"latestoccurence,omitempty"`
+	Occurrences      int       `json:"occurrences,omitempty"`
+	ExpiresAt        Timestamp `json:"expiresat,omitempty"`
+}
+
+// IssueEscalation support case opened for an issue
+type IssueEscalation struct {
+	Trigger       string      `json:"trigger,omitempty"`
+	User          string      `json:"user,omitempty"`
+	Timestamp     Timestamp   `json:"timestamp,omitempty"`
+	LastUpdatedAt Timestamp   `json:"lastupdatedat,omitempty"`
+	CRM           string      `json:"crm,omitempty"`
+	CaseID        string      `json:"caseid,omitempty"`
+	Href          string      `json:"href,omitempty"`
+	CaseStatus    string      `json:"casestatus,omitempty"`
+	CaseAction    string      `json:"caseaction,omitempty"`
+	CaseHistory   interface{} `json:"casehistory,omitempty"`
+}
+
+// NimbleData Nimble specific issue details
+type NimbleData struct {
+	AutomationCode string `json:"automationcode,omitempty"`
+	GroupName      string `json:"groupname,omitempty"`
+	GroupID        string `json:"groupid,omitempty"`
+	Severity       string `json:"severity,omitempty"`
+	Value          string `json:"value,omitempty"`
+}
+
+// AffectedSystem is a system (array) affected by an issue
+type AffectedSystem struct {
+	URN      string `json:"urn,omitempty"`
+	Serial   string `json:"serial,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Model    string `json:"model,omitempty"`
+	Platform string `json:"platform,omitempty"`
+}
+
+// complete fills serial and platform from the urn of the system, e.g. urn:nimble:array:<serial>
+func (s AffectedSystem) complete() AffectedSystem {
+	parts := strings.Split(s.URN, ":")
+	if len(parts) < 4 || parts[0] != "urn" {
+		return s
+	}
+	if s.Platform == "" {
+		s.Platform = parts[1]
+	}
+	if s.Serial == "" {
+		s.Serial = parts[len(parts)-1]
+	}
+	return s
+}
+
+// Issue is a wellness issue as returned by the issues object set
+type Issue struct {
+	ID             string            `json:"_id,omitempty"`
+	UUID           string            `json:"uuid,omitempty"`
+	AutomationUUID string            `json:"automationuuid,omitempty"`
+	Domain         string            `json:"domain,omitempty"`
+	Tenant         string            `json:"tenant,omitempty"`
+	Condition      *IssueCondition   `json:"condition,omitempty"`
+	Object         *IssueObject      `json:"object,omitempty"`
+	Asset          *IssueObject      `json:"asset,omitempty"`
+	Title          string            `json:"title,omitempty"`
+	Body           *IssueBody        `json:"body,omitempty"`
+	Status         *IssueStatus      `json:"status,omitempty"`
+	Escalation     []IssueEscalation `json:"escalation,omitempty"`
+	NimbleData     *NimbleData       `json:"nimbledata,omitempty"`
+	// AffectedSystems lists the systems of issues affecting more than one system, see Systems
+	AffectedSystems []AffectedSystem `json:"systems,omitempty"`
+	// Device is the embedded device configuration, it is only decoded on demand by DecodeDevice
+	Device json.RawMessage `json:"device,omitempty"`
+}
+
+// DecodeDevice decodes the embedded device configuration into v, list views can skip the decoding
+func (i Issue) DecodeDevice(v interface{}) error {
+	if len(i.Device) == 0 || string(i.Device) == "null" {
+		return fmt.Errorf("issue %s has no device", i.UUID)
+	}
+	return json.Unmarshal(i.Device, v)
+}
+
+// Systems returns the systems affected by the issue, either the listed systems or the asset of the issue
+func (i Issue) Systems() []AffectedSystem {
+	systems := []AffectedSystem{}
+	for _, system := range i.AffectedSystems {
+		systems = append(systems, system.complete())
+	}
+	if len(systems) == 0 && i.Asset != nil && i.Asset.URN != "" {
+		systems = append(systems, AffectedSystem{URN: i.Asset.URN, Name: i.Asset.Name}.complete())
+	}
+	return systems
+}
+
+// FlattenIssues converts issues into rows for tabular output. Nested fields are flattened with dotted
+// keys (e.g. condition.severity, escalation.0.caseid), missing fields are omitted.
+func FlattenIssues(issues []Issue) ([]map[string]string, error) {
+	rows := make([]map[string]string, 0, len(issues))
+	for _, issue := range issues {
+		raw, err := json.Marshal(issue)
+		if err != nil {
+			return nil, err
+		}
+		var doc interface{}
+		if err := json.Unmarshal(raw, &doc); err != nil {
+			return nil, err
+		}
+		row := map[string]string{}
+		flatten(row, "", doc)
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// FlattenedColumns returns the sorted union of the keys of flattened rows, to be used as table header
+func FlattenedColumns(rows []map[string]string) []string {
+	seen := map[string]bool{}
+	columns := []string{}
+	for _, row := range rows {
+		for key := range row {
+			if !seen[key] {
+				seen[key] = true
+				columns = append(columns, key)
+			}
+		}
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+// flatten adds the leaves of a decoded JSON document to row
+func flatten(row map[string]string, prefix string, v interface{}) {
+	join := func(key string) string {
+		if prefix == "" {
+			return key
+		}
+		return prefix + "." + key
+	}
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			flatten(row, join(key), value)
+		}
+	case []interface{}:
+		for i, value := range v {
+			flatten(row, join(strconv.Itoa(i)), value)
+		}
+	case nil:
+	case string:
+		row[prefix] = v
+	case float64:
+		row[prefix] = strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		row[prefix] = fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,176 @@
+package infosight
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFlattenIssues(t *testing.T) {
+	var issues []Issue
+	if err := json.Unmarshal([]byte(issuesFixture), &issues); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := FlattenIssues(issues)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(rows))
+	}
+
+	row := rows[1]
+	expected := map[string]string{
+		"_id":                 "5d9eb55a28c7eb0001f472ec",
+		"condition.severity":  "non-critical",
+		"status.occurrences":  "1",
+		"escalation.0.caseid": "5003000000D8cuI",
+		"nimbledata.value":    "new",
+	}
+	for key, value := range expected {
+		if row[key] != value {
+			t.Errorf("expected %s=%q, got %q", key, value, row[key])
+		}
+	}
+	if _, ok := rows[0]["title"]; ok {
+		t.Errorf("expected missing fields to be omitted")
+	}
+
+	columns := FlattenedColumns(rows)
+	if columns[0] != "_id" || !reflect.DeepEqual(columns, FlattenedColumns([]map[string]string{rows[1], rows[0]})) {
+		t.Errorf("unexpected columns %v", columns)
+	}
+	union := map[string]bool{}
+	for _, column := range columns {
+		union[column] = true
+	}
+	if !union["title"] || !union["status.expiresat"] {
+		t.Errorf("expected the union of all row keys, got %v", columns)
+	}
+}
+
+func TestTimestamp(t *testing.T) {
+	expected := time.Date(2020, 6, 10, 0, 6, 9, 994000000, time.UTC)
+	tests := []struct {
+		name     string
+		json     string
+		expected time.Time
+	}{
+		{"epoch millis", `1591747569994`, expected},
+		{"epoch millis string", `"1591747569994"`, expected},
+		{"epoch seconds", `1591747569`, expected.Truncate(time.Second)},
+		{"epoch seconds fraction", `1591747569.994`, expected},
+		{"rfc3339", `"2020-06-10T00:06:09.994Z"`, expected},
+		{"rfc3339 offset", `"2020-06-10T02:06:09.994+02:00"`, expected},
+		{"rfc3339 trailing space", `"2020-06-10T00:06:09.994Z "`, expected},
+		{"null", `null`, time.Time{}},
+		{"empty", `""`, time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ts Timestamp
+			if err := json.Unmarshal([]byte(tt.json), &ts); err != nil {
+				t.Fatal(err)
+			}
+			if !ts.Equal(tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, ts.Time)
+			}
+		})
+	}
+
+	for _, invalid := range []string{`"yesterday"`, `true`, `{}`} {
+		var ts Timestamp
+		if err := json.Unmarshal([]byte(invalid), &ts); err == nil {
+			t.Errorf("expected an error for %s", invalid)
+		}
+	}
+}
+
+func TestTimestampMarshal(t *testing.T) {
+	status := IssueStatus{Timestamp: Timestamp{time.Date(2020, 5, 29, 4, 58, 53, 643000000, time.FixedZone("CEST", 2*60*60))}}
+	raw, err := json.Marshal(status)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"timestamp":"2020-05-29T02:58:53.643Z","initialoccurence":null,"latestoccurence":null,"expiresat":null}`
+	if string(raw) != expected {
+		t.Errorf("expected %s, got %s", expected, raw)
+	}
+
+	var decoded IssueStatus
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Timestamp.Equal(status.Timestamp.Time) || !decoded.ExpiresAt.IsZero() {
+		t.Errorf("unexpected round trip %+v", decoded)
+	}
+}
+
+func TestIssueSystems(t *testing.T) {
+	tests := []struct {
+		name     string
+		payload  string
+		expected []AffectedSystem
+	}{
+		{
+			"single system",
+			`{"asset":{"urn":"urn:nimble:array:AF-12345","name":"MJ-SAN1"}}`,
+			[]AffectedSystem{{URN: "urn:nimble:array:AF-12345", Serial: "AF-12345", Name: "MJ-SAN1", Platform: "nimble"}},
+		},
+		{
+			"multiple systems",
+			`{"asset":{"urn":"urn:nimble:array:AF-12345"},"systems":[
+				{"urn":"urn:nimble:array:AF-12345","name":"MJ-SAN1","model":"AF40"},
+				{"serial":"AF-67890","name":"MJ-SAN2","model":"AF40","platform":"nimble"}]}`,
+			[]AffectedSystem{
+				{URN: "urn:nimble:array:AF-12345", Serial: "AF-12345", Name: "MJ-SAN1", Model: "AF40", Platform: "nimble"},
+				{Serial: "AF-67890", Name: "MJ-SAN2", Model: "AF40", Platform: "nimble"},
+			},
+		},
+		{"no system", `{"title":"unrelated"}`, []AffectedSystem{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var issue Issue
+			if err := json.Unmarshal([]byte(tt.payload), &issue); err != nil {
+				t.Fatal(err)
+			}
+			if systems := issue.Systems(); !reflect.DeepEqual(systems, tt.expected) {
+				t.Errorf("expected %+v, got %+v", tt.expected, systems)
+			}
+		})
+	}
+}
+
+func TestIssueDecodeDevice(t *testing.T) {
+	var issue Issue
+	raw := `{"uuid":"5d9eb55a28c7eb0001f472eb","device":{"serial":"AF-012345","config":{"volumes":[{"name":"vol1","size":1024}]}}}`
+	if err := json.Unmarshal([]byte(raw), &issue); err != nil {
+		t.Fatal(err)
+	}
+	if string(issue.Device) != `{"serial":"AF-012345","config":{"volumes":[{"name":"vol1","size":1024}]}}` {
+		t.Fatalf("expected the device to be kept raw, got %s", issue.Device)
+	}
+
+	var device struct {
+		Serial string `json:"serial"`
+		Config struct {
+			Volumes []struct {
+				Name string `json:"name"`
+				Size int    `json:"size"`
+			} `json:"volumes"`
+		} `json:"config"`
+	}
+	if err := issue.DecodeDevice(&device); err != nil {
+		t.Fatal(err)
+	}
+	if device.Serial != "AF-012345" || len(device.Config.Volumes) != 1 || device.Config.Volumes[0].Size != 1024 {
+		t.Errorf("unexpected device %+v", device)
+	}
+
+	if err := (Issue{UUID: "5d9eb55a28c7eb0001f472eb"}).DecodeDevice(&device); err == nil {
+		t.Error("expected an error for an issue without device")
+	}
+}
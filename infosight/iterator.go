@@ -0,0 +1,257 @@
+package infosight
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// defaultPageSize is the number of objects InfoSight returns if no limit is requested
+const defaultPageSize = 200
+
+// IteratorErrorKind classifies the error of a page fetched by an iterator
+type IteratorErrorKind int
+
+const (
+	// IteratorErrorOther covers errors not caused by the server or the network, e.g. invalid options or a canceled context
+	IteratorErrorOther IteratorErrorKind = iota
+	// IteratorErrorNetwork is a ConnectionError, the server could not be reached
+	IteratorErrorNetwork
+	// IteratorErrorFault is a FaultResponse returned by the server
+	IteratorErrorFault
+	// IteratorErrorDecode is a DecodeError or ContentTypeError, the response could not be decoded
+	IteratorErrorDecode
+)
+
+func (k IteratorErrorKind) String() string {
+	switch k {
+	case IteratorErrorNetwork:
+		return "network"
+	case IteratorErrorFault:
+		return "fault"
+	case IteratorErrorDecode:
+		return "decode"
+	default:
+		return "other"
+	}
+}
+
+// IteratorError is returned by Iterator.Err if fetching a page failed
+type IteratorError struct {
+	// Offset of the page that failed, i.e. the objects fetched before, see ResumeObjectSet
+	Offset int
+	Kind   IteratorErrorKind
+	Err    error
+}
+
+func (e *IteratorError) Error() string {
+	return fmt.Sprintf("infosight: fetching page at offset %d (%s): %v", e.Offset, e.Kind, e.Err)
+}
+
+// Unwrap returns the error of the page
+func (e *IteratorError) Unwrap() error {
+	return e.Err
+}
+
+// newIteratorError wraps err of the page at offset in an IteratorError
+func newIteratorError(offset int, err error) *IteratorError {
+	var connErr *ConnectionError
+	var fault *FaultResponse
+	var decodeErr *DecodeError
+	var contentTypeErr *ContentTypeError
+	kind := IteratorErrorOther
+	switch {
+	case errors.As(err, &connErr):
+		kind = IteratorErrorNetwork
+	case errors.As(err, &fault):
+		kind = IteratorErrorFault
+	case errors.As(err, &decodeErr), errors.As(err, &contentTypeErr):
+		kind = IteratorErrorDecode
+	}
+	return &IteratorError{Offset: offset, Kind: kind, Err: err}
+}
+
+// maxDedupeKeys is the number of keys remembered by WithDedupeKey
+const maxDedupeKeys = 100000
+
+// dedupeSet remembers the keys of the last objects returned by an iterator
+type dedupeSet struct {
+	key   string
+	max   int
+	seen  map[string]bool
+	order []string
+	next  int
+}
+
+// filter removes the objects of data whose key was seen before
+func (d *dedupeSet) filter(data []interface{}) []interface{} {
+	unique := data[:0]
+	for _, item := range data {
+		obj, ok := item.(map[string]interface{})
+		if !ok || obj[d.key] == nil {
+			unique = append(unique, item)
+			continue
+		}
+		id := fmt.Sprint(obj[d.key])
+		if d.seen[id] {
+			continue
+		}
+		d.seen[id] = true
+		if len(d.order) < d.max {
+			d.order = append(d.order, id)
+		} else {
+			delete(d.seen, d.order[d.next])
+			d.order[d.next] = id
+			d.next = (d.next + 1) % d.max
+		}
+		unique = append(unique, item)
+	}
+	return unique
+}
+
+// Iterator walks an object set page by page
+type Iterator struct {
+	w         *Wellness
+	ctx       context.Context
+	objectSet string
+	opts      []RequestOption
+
+	limit    int
+	skip     int
+	total    int
+	progress func(fetched int, total int)
+	dedupe   *dedupeSet
+	page     *APIResponse
+	done     bool
+	err      error
+}
+
+// IterateObjectSet returns an iterator fetching the object set in pages of pageSize objects.
+// If pageSize is 0, the limit of the registered defaults or the server default page size is used.
+func (w *Wellness) IterateObjectSet(ctx context.Context, objectSet string, pageSize int, opts ...RequestOption) *Iterator {
+	it := &Iterator{
+		w:         w,
+		ctx:       ctx,
+		objectSet: objectSet,
+		opts:      opts,
+		limit:     pageSize,
+	}
+	// invalid options are reported by the first call to Next
+	if o, err := w.requestOptions(ctx, objectSet, opts...); err == nil {
+		it.progress = o.progress
+		if o.dedupeKey != "" {
+			it.dedupe = &dedupeSet{key: o.dedupeKey, max: maxDedupeKeys, seen: map[string]bool{}}
+		}
+		if pageSize <= 0 {
+			if limit, err := strconv.Atoi(o.query.Get("limit")); err == nil && limit > 0 {
+				it.limit = limit
+			}
+		}
+	}
+	if it.limit <= 0 {
+		it.limit = defaultPageSize
+	}
+	if w.maxPageSize > 0 && it.limit > w.maxPageSize {
+		it.limit = w.maxPageSize
+	}
+	return it
+}
+
+// ResumeObjectSet returns an iterator like IterateObjectSet which skips the first offset objects,
+// e.g. to resume an interrupted export at the Offset of its iterator
+func (w *Wellness) ResumeObjectSet(ctx context.Context, objectSet string, pageSize int, offset int, opts ...RequestOption) *Iterator {
+	it := w.IterateObjectSet(ctx, objectSet, pageSize, opts...)
+	if offset > 0 {
+		it.skip = offset
+	}
+	return it
+}
+
+// Next fetches the next page. It returns false once the object set is exhausted or an error occurred.
+func (it *Iterator) Next() bool {
+	if it.done || it.err != nil {
+		return false
+	}
+	if err := it.ctx.Err(); err != nil {
+		it.err = err
+		return false
+	}
+
+	opts := append(append([]RequestOption{}, it.opts...), WithPaging(it.skip, it.limit))
+	page, err := it.w.GetObjectSetContext(it.ctx, it.objectSet, opts...)
+	if err != nil {
+		it.err = newIteratorError(it.skip, err)
+		return false
+	}
+
+	// the server clamps the limit to its maximum page size, continue with the effective limit
+	if limit := page.Request.EffectiveLimit(); limit > 0 && limit < it.limit {
+		it.limit = limit
+	}
+	// pagination driven by the body takes precedence over the computed offset
+	if page.NextOffset != nil {
+		it.skip = *page.NextOffset
+	} else {
+		it.skip += len(page.Data)
+	}
+	if page.HasMore != nil {
+		it.done = !*page.HasMore
+	} else if len(page.Data) < it.limit {
+		it.done = true
+	}
+	if page.Request != nil && page.Request.Paging != nil && page.Request.Paging.Total > 0 {
+		it.total = page.Request.Paging.Total
+	}
+	if it.progress != nil && len(page.Data) > 0 {
+		it.progress(it.skip, it.total)
+	}
+	if len(page.Data) == 0 {
+		return false
+	}
+	if it.dedupe != nil {
+		if page.Data = it.dedupe.filter(page.Data); len(page.Data) == 0 {
+			// the whole page was returned before
+			return it.Next()
+		}
+	}
+	it.page = page
+	return true
+}
+
+// Offset returns the number of objects before the next page, i.e. the objects fetched so far
+// including the offset the iterator was resumed at
+func (it *Iterator) Offset() int {
+	return it.skip
+}
+
+// Page returns the page fetched by the last call to Next
+func (it *Iterator) Page() *APIResponse {
+	return it.page
+}
+
+// Err returns the error that stopped the iteration, if any. Errors fetching a page are returned as
+// *IteratorError with the offset of the page, the underlying error is available with errors.As.
+func (it *Iterator) Err() error {
+	return it.err
+}
+
+// GetObjectSetPaginated fetches the first page of an object set and returns it together with a function
+// fetching the following pages one by one. The function returns the page and whether further pages follow it,
+// i.e. false for the last page (if its end is known from a short page or the total) and once the object set is exhausted.
+func (w *Wellness) GetObjectSetPaginated(ctx context.Context, objectSet string, pageSize int, opts ...RequestOption) (APIResponse, func() (APIResponse, bool, error), error) {
+	it := w.IterateObjectSet(ctx, objectSet, pageSize, opts...)
+	next := func() (APIResponse, bool, error) {
+		if !it.Next() {
+			return APIResponse{}, false, it.Err()
+		}
+		hasMore := !it.done && (it.total <= 0 || it.skip < it.total)
+		return *it.Page(), hasMore, nil
+	}
+
+	first, _, err := next()
+	if err != nil {
+		return APIResponse{}, nil, err
+	}
+	return first, next, nil
+}
@@ -0,0 +1,70 @@
+package infosight
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Link is a resource related to an issue, e.g. its support case or a knowledge base article
+type Link struct {
+	// Rel is "case" for support cases and "related" for links in the issue description
+	Rel   string
+	Href  string
+	Title string
+}
+
+// linkPattern finds urls in issue descriptions
+var linkPattern = regexp.MustCompile(`https?://[^\s"'<>()]+`)
+
+// RelatedLinks returns the links of the support cases of an issue and the urls mentioned in its description
+func (i Issue) RelatedLinks() []Link {
+	links := []Link{}
+	seen := map[string]bool{}
+	add := func(link Link) {
+		if link.Href != "" && !seen[link.Href] {
+			seen[link.Href] = true
+			links = append(links, link)
+		}
+	}
+	for _, escalation := range i.Escalation {
+		add(Link{Rel: "case", Href: escalation.Href, Title: escalation.CaseID})
+	}
+	if i.Body != nil {
+		for _, href := range linkPattern.FindAllString(i.Body.Content, -1) {
+			add(Link{Rel: "related", Href: strings.TrimRight(href, ".,;:"), Title: i.Title})
+		}
+	}
+	return links
+}
+
+// FetchLink fetches the content of a link through the authenticated client. To not leak the access
+// token, only links to the configured server are fetched.
+func (w *Wellness) FetchLink(ctx context.Context, link string) ([]byte, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return nil, err
+	}
+	server, err := url.Parse(w.Server)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(u.Scheme, server.Scheme) || !strings.EqualFold(u.Host, server.Host) {
+		return nil, fmt.Errorf("link %s does not point to %s", u.Redacted(), server.Host)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	r, err := w.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	return ioutil.ReadAll(r.Body)
+}
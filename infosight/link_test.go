@@ -0,0 +1,61 @@
+package infosight
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestRelatedLinks(t *testing.T) {
+	var issue Issue
+	err := json.Unmarshal([]byte(`{
+		"title": "Drive failed",
+		"body": {"content": "Replace the drive, see https://infosight.example.com/kb/1234. Details: http://docs.example.com/drives"},
+		"escalation": [{"caseid": "5003000000D8cuI", "href": "https://support.hpe.com/case/5003000000D8cuI"}, {"caseid": "none"}]
+	}`), &issue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Link{
+		{Rel: "case", Href: "https://support.hpe.com/case/5003000000D8cuI", Title: "5003000000D8cuI"},
+		{Rel: "related", Href: "https://infosight.example.com/kb/1234", Title: "Drive failed"},
+		{Rel: "related", Href: "http://docs.example.com/drives", Title: "Drive failed"},
+	}
+	if links := issue.RelatedLinks(); !reflect.DeepEqual(links, expected) {
+		t.Errorf("expected %+v, got %+v", expected, links)
+	}
+	if links := (Issue{}).RelatedLinks(); len(links) != 0 {
+		t.Errorf("expected no links, got %+v", links)
+	}
+}
+
+func TestFetchLink(t *testing.T) {
+	var auth string
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		fmt.Fprint(w, "Replace the drive")
+	})
+	c, err := NewClient(ts.URL, WithLogin("key", "secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := c.Wellness.FetchLink(context.Background(), ts.URL+"/kb/1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "Replace the drive" || auth != "Bearer token-1" {
+		t.Errorf("unexpected content %q (%s)", content, auth)
+	}
+
+	auth = ""
+	if _, err := c.Wellness.FetchLink(context.Background(), "https://support.hpe.com/case/1"); err == nil {
+		t.Error("expected an error for a foreign host")
+	}
+	if auth != "" {
+		t.Error("expected no request for a foreign host")
+	}
+}
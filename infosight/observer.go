@@ -0,0 +1,37 @@
+package infosight
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Observer is notified about requests and token refreshes of a client, e.g. to export metrics
+// (see the metrics package for Prometheus). Its methods are called concurrently.
+type Observer interface {
+	// ObserveRequest is called once the response headers of a request are received (including retries),
+	// status is 0 if err is set
+	ObserveRequest(method string, status int, duration time.Duration, err error)
+	// ObserveTokenRefresh is called after every token request of the client
+	ObserveTokenRefresh(err error)
+}
+
+// WithObserver notifies observer about every request and token refresh of the client
+func WithObserver(observer Observer) ClientOption {
+	return func(c *Client) error {
+		if observer == nil {
+			return errors.New("nil observer")
+		}
+		c.observer = observer
+		return nil
+	}
+}
+
+// observeRequest passes a request sent at start to the observer, r may be nil if err is set
+func (c *Client) observeRequest(start time.Time, req *http.Request, r *http.Response, err error) {
+	status := 0
+	if r != nil {
+		status = r.StatusCode
+	}
+	c.observer.ObserveRequest(req.Method, status, time.Since(start), err)
+}
@@ -0,0 +1,61 @@
+package infosight
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingObserver struct {
+	mu       sync.Mutex
+	statuses []int
+	tokens   int
+}
+
+func (o *recordingObserver) ObserveRequest(method string, status int, duration time.Duration, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.statuses = append(o.statuses, status)
+}
+
+func (o *recordingObserver) ObserveTokenRefresh(err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.tokens++
+}
+
+func TestWithObserver(t *testing.T) {
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("domain") == "urn:missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		issuesHandler(`[]`)(w, r)
+	})
+	observer := &recordingObserver{}
+	c, err := NewClient(ts.URL, WithObserver(observer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Wellness.GetIssues(); err != nil {
+		t.Fatal(err)
+	}
+	missing, err := c.Clone(WithDomain("urn:missing"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := missing.Wellness.GetIssues(); err == nil {
+		t.Fatal("expected a fault")
+	}
+
+	if len(observer.statuses) != 2 || observer.statuses[0] != http.StatusOK || observer.statuses[1] != http.StatusNotFound {
+		t.Errorf("unexpected observed statuses %v", observer.statuses)
+	}
+	if observer.tokens != 1 {
+		t.Errorf("expected 1 token refresh, got %d", observer.tokens)
+	}
+	if _, err := NewClient(ts.URL, WithObserver(nil)); err == nil {
+		t.Error("expected an error for a nil observer")
+	}
+}
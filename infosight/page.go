@@ -0,0 +1,72 @@
+package infosight
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+)
+
+// Page is a typed page of an object set
+type Page[T any] struct {
+	Items []T
+	Skip  int
+	Limit int
+	// Total number of objects in the object set, 0 if not reported by the server
+	Total int
+}
+
+// DecodeData decodes the data of a response into a slice of T with encoding/json. GetPage and the typed
+// methods of Wellness decode with the unmarshaler of the client instead, see WithJSONUnmarshaler.
+func DecodeData[T any](r *APIResponse) ([]T, error) {
+	return decodeData[T](r, json.Unmarshal)
+}
+
+// decodeData decodes the data of a response into a slice of T with unmarshal (json.Unmarshal if nil)
+func decodeData[T any](r *APIResponse, unmarshal func([]byte, interface{}) error) ([]T, error) {
+	if unmarshal == nil {
+		unmarshal = json.Unmarshal
+	}
+	raw, err := json.Marshal(r.Data)
+	if err != nil {
+		return nil, err
+	}
+	items := []T{}
+	if err := unmarshal(raw, &items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// typedResponse is a response with objects of type T, decoded in one pass by GetPage
+type typedResponse[T any] struct {
+	Request *RequestInfo `json:"request,omitempty"`
+	Data    []T          `json:"data,omitempty"`
+}
+
+// GetPage fetches a page of an object set and decodes its objects into T with the unmarshaler of the client
+func GetPage[T any](w *Wellness, ctx context.Context, objectSet string, opts ...RequestOption) (Page[T], error) {
+	var page Page[T]
+
+	o, err := w.requestOptions(ctx, objectSet, opts...)
+	if err != nil {
+		return page, err
+	}
+	page.Skip, _ = strconv.Atoi(o.query.Get("skip"))
+	page.Limit, _ = strconv.Atoi(o.query.Get("limit"))
+
+	var r typedResponse[T]
+	if _, err := w.fetchInto(ctx, objectSet, &r, opts...); err != nil {
+		return page, err
+	}
+	if r.Request != nil && r.Request.Paging != nil {
+		page.Skip = r.Request.Paging.Skip
+		page.Limit = r.Request.Paging.Limit
+		page.Total = r.Request.Paging.Total
+	}
+
+	page.Items = r.Data
+	if page.Items == nil {
+		page.Items = []T{}
+	}
+	return page, nil
+}
@@ -0,0 +1,74 @@
+package infosight
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// GetAllObjectSetParallel fetches all objects of an object set like an iterator, but once the first page reported
+// the total number of objects the remaining pages are fetched concurrently by up to workers requests (further
+// bounded by WithMaxConcurrentRequests and WithRateLimiter). The objects are returned in page order. If a page
+// fails the remaining requests are canceled and an *IteratorError with the offset of the page is returned.
+// Servers not reporting a total are paged sequentially.
+func (w *Wellness) GetAllObjectSetParallel(ctx context.Context, objectSet string, pageSize int, workers int, opts ...RequestOption) ([]interface{}, error) {
+	if workers < 1 {
+		return nil, errors.New("at least one worker required")
+	}
+
+	it := w.IterateObjectSet(ctx, objectSet, pageSize, opts...)
+	if !it.Next() {
+		return []interface{}{}, it.Err()
+	}
+	objects := append([]interface{}{}, it.Page().Data...)
+	if it.done {
+		return objects, nil
+	}
+	if it.total <= 0 {
+		for it.Next() {
+			objects = append(objects, it.Page().Data...)
+		}
+		return objects, it.Err()
+	}
+
+	// pages are fetched with the limit the server applied to the first page
+	offsets := []int{}
+	for offset := it.skip; offset < it.total; offset += it.limit {
+		offsets = append(offsets, offset)
+	}
+	pages := make([][]interface{}, len(offsets))
+	var mu sync.Mutex
+	fetched := it.skip
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(workers)
+	for i, offset := range offsets {
+		i, offset := i, offset
+		g.Go(func() error {
+			pageOpts := append(append([]RequestOption{}, opts...), WithPaging(offset, it.limit))
+			page, err := w.GetObjectSetContext(gctx, objectSet, pageOpts...)
+			if err != nil {
+				return newIteratorError(offset, err)
+			}
+			pages[i] = page.Data
+			if it.progress != nil {
+				mu.Lock()
+				fetched += len(page.Data)
+				it.progress(fetched, it.total)
+				mu.Unlock()
+			}
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	for _, page := range pages {
+		if it.dedupe != nil {
+			page = it.dedupe.filter(page)
+		}
+		objects = append(objects, page...)
+	}
+	return objects, nil
+}
@@ -0,0 +1,91 @@
+package infosight
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestGetAllObjectSetParallel(t *testing.T) {
+	var requests, inFlight, maxInFlight int32
+	paged := pagedHandler(1045)
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+				break
+			}
+		}
+		if r.URL.Query().Get("skip") == "700" && r.URL.Query().Get("domain") == "urn:failing" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		paged(w, r)
+	})
+	c, err := NewClient(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	objects, err := c.Wellness.GetAllObjectSetParallel(context.Background(), "issues", 100, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 1045 || atomic.LoadInt32(&requests) != 11 {
+		t.Fatalf("expected 1045 objects in 11 requests, got %d in %d", len(objects), requests)
+	}
+	seen := map[string]bool{}
+	for _, object := range objects {
+		seen[object.(map[string]interface{})["_id"].(string)] = true
+	}
+	if len(seen) != 1045 || objects[1044].(map[string]interface{})["_id"] != fmt.Sprintf("%024x", 1044) {
+		t.Errorf("expected all objects in page order, got %d distinct", len(seen))
+	}
+	if max := atomic.LoadInt32(&maxInFlight); max > 4 {
+		t.Errorf("expected at most 4 concurrent requests, got %d", max)
+	}
+
+	_, err = c.Wellness.GetAllObjectSetParallel(ContextWithDomain(context.Background(), "urn:failing"), "issues", 100, 4)
+	var itErr *IteratorError
+	if !errors.As(err, &itErr) || itErr.Offset != 700 || itErr.Kind != IteratorErrorFault {
+		t.Errorf("expected a fault of the page at offset 700, got %v", err)
+	}
+
+	if _, err := c.Wellness.GetAllObjectSetParallel(context.Background(), "issues", 100, 0); err == nil {
+		t.Error("expected error without workers")
+	}
+}
+
+func TestGetAllObjectSetParallelWithoutTotal(t *testing.T) {
+	var requests int32
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		skip, _ := strconv.Atoi(r.URL.Query().Get("skip"))
+		data := []string{}
+		for i := skip; i < 25 && i < skip+10; i++ {
+			data = append(data, fmt.Sprintf(`{"_id":"%024x"}`, i))
+		}
+		issuesHandler("["+strings.Join(data, ",")+"]")(w, r)
+	})
+	c, err := NewClient(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// without a total the pages are fetched sequentially until the first short page
+	objects, err := c.Wellness.GetAllObjectSetParallel(context.Background(), "issues", 10, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 25 || atomic.LoadInt32(&requests) != 3 {
+		t.Errorf("expected 25 objects in 3 requests, got %d in %d", len(objects), requests)
+	}
+}
@@ -0,0 +1,84 @@
+package infosight
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RateLimiter limits the rate of requests, WaitN blocks until n requests may be sent. It is implemented by
+// TokenBucket and by rate.Limiter of golang.org/x/time/rate.
+type RateLimiter interface {
+	WaitN(ctx context.Context, n int) error
+}
+
+// WithRateLimiter waits for limiter before every request (including retries, but not token requests). Each request
+// takes weight tokens of the limiter. Passing one limiter to the clients of several tenants caps their aggregate
+// rate to InfoSight, the weight of a tenant determines its share.
+func WithRateLimiter(limiter RateLimiter, weight int) ClientOption {
+	return func(c *Client) error {
+		if limiter == nil {
+			return errors.New("nil rate limiter")
+		}
+		if weight < 1 {
+			return fmt.Errorf("invalid rate limit weight %d", weight)
+		}
+		c.rateLimiter = limiter
+		c.rateLimitWeight = weight
+		return nil
+	}
+}
+
+// TokenBucket is a RateLimiter allowing rate requests per second with bursts of up to burst requests.
+// It is safe for concurrent use, e.g. by several clients.
+type TokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewTokenBucket creates a full TokenBucket allowing rate requests per second and bursts of up to burst requests
+func NewTokenBucket(rate float64, burst int) (*TokenBucket, error) {
+	if rate <= 0 || burst < 1 {
+		return nil, fmt.Errorf("invalid rate %v with burst %d", rate, burst)
+	}
+	return &TokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}, nil
+}
+
+// WaitN takes n tokens from the bucket and waits until they are refilled if the bucket is empty.
+// If ctx is done before, the tokens are returned and the context error is returned.
+func (b *TokenBucket) WaitN(ctx context.Context, n int) error {
+	if float64(n) > b.burst {
+		return fmt.Errorf("%d tokens exceed the burst of %v", n, b.burst)
+	}
+	b.mu.Lock()
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	// the tokens are reserved right away, so waiting callers are served in order
+	b.tokens -= float64(n)
+	deficit := -b.tokens
+	b.mu.Unlock()
+	if deficit <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(time.Duration(deficit / b.rate * float64(time.Second)))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		b.mu.Lock()
+		b.tokens += float64(n)
+		b.mu.Unlock()
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
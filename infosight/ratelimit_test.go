@@ -0,0 +1,98 @@
+package infosight
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithRateLimiterShared(t *testing.T) {
+	var requests int32
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		issuesHandler(`[]`)(w, r)
+	})
+	limiter, err := NewTokenBucket(50, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tenantA, err := NewClient(ts.URL, WithRateLimiter(limiter, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tenantB, err := NewClient(ts.URL, WithRateLimiter(limiter, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// make sure both clients have a token, token requests are not limited
+	for _, c := range []*Client{tenantA, tenantB} {
+		if _, err := c.Token(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for _, c := range []*Client{tenantA, tenantB} {
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func(c *Client) {
+				defer wg.Done()
+				if _, err := c.Wellness.GetIssues(); err != nil {
+					t.Error(err)
+				}
+			}(c)
+		}
+	}
+	wg.Wait()
+	// 10 requests at 50 per second with a burst of 1 take at least 9 * 20ms
+	if elapsed := time.Since(start); elapsed < 170*time.Millisecond {
+		t.Errorf("expected the shared limiter to cap the aggregate rate, 10 requests took %v", elapsed)
+	}
+	if n := atomic.LoadInt32(&requests); n != 10 {
+		t.Errorf("expected 10 requests, got %d", n)
+	}
+
+	if _, err := NewClient(ts.URL, WithRateLimiter(limiter, 0)); err == nil {
+		t.Error("expected an error for a zero weight")
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	bucket, err := NewTokenBucket(10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	// the full bucket allows a burst
+	start := time.Now()
+	if err := bucket.WaitN(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) > 20*time.Millisecond {
+		t.Error("expected the burst not to wait")
+	}
+	// a weight of 2 waits for 2 tokens
+	start = time.Now()
+	if err := bucket.WaitN(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("expected to wait for 2 tokens at 10 per second, waited %v", elapsed)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := bucket.WaitN(canceled, 1); err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if err := bucket.WaitN(ctx, 3); err == nil {
+		t.Error("expected an error for more tokens than the burst")
+	}
+	if _, err := NewTokenBucket(0, 1); err == nil {
+		t.Error("expected an error for a zero rate")
+	}
+}
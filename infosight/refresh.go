@@ -0,0 +1,93 @@
+package infosight
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// autoRefreshRetryDelay is the delay before retrying a failed background token refresh
+var autoRefreshRetryDelay = 5 * time.Second
+
+// WithAutoRefreshBefore refreshes the access token in the background d before it expires, so requests never wait
+// for the token endpoint. d should exceed the expiry delta (see WithExpiryDelta), otherwise requests refresh the token
+// first. Tokens living shorter than 2*d are refreshed after half of their remaining lifetime. The background refresh
+// stops once the context of the client is done or Close is called.
+func WithAutoRefreshBefore(d time.Duration) ClientOption {
+	return func(c *Client) error {
+		if d <= 0 {
+			return fmt.Errorf("invalid auto refresh duration %v", d)
+		}
+		c.autoRefreshBefore = d
+		return nil
+	}
+}
+
+// startAutoRefresh starts the background token refresh if enabled and the client owns an OAuth token
+func (c *Client) startAutoRefresh() {
+	if c.autoRefreshBefore <= 0 || c.tokenOwner != nil || c.apiKeyHeader != "" {
+		return
+	}
+	c.refreshStop = make(chan struct{})
+	c.refreshDone = make(chan struct{})
+	go c.autoRefresh()
+}
+
+// autoRefresh fetches the token and refreshes it before it expires until the refresh is stopped
+func (c *Client) autoRefresh() {
+	defer close(c.refreshDone)
+	token, err := c.Token()
+	for {
+		var expired <-chan time.Time
+		if err != nil {
+			c.Warnf("refreshing token in the background: %v", err)
+			expired = time.After(autoRefreshRetryDelay)
+		} else if !token.Expiry.IsZero() {
+			remaining := time.Until(token.Expiry)
+			wait := remaining - c.autoRefreshBefore
+			if wait < remaining/2 {
+				wait = remaining / 2
+			}
+			expired = time.After(wait)
+		}
+
+		select {
+		case <-c.refreshStop:
+			return
+		case <-c.ctx.Done():
+			return
+		case <-expired:
+		}
+		token, err = c.refreshToken()
+	}
+}
+
+// refreshToken fetches a new token and replaces the cached one, requests keep using the cached token meanwhile
+func (c *Client) refreshToken() (*oauth2.Token, error) {
+	token, err := c.fetchToken()
+	if err != nil {
+		return nil, err
+	}
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+	c.token = token
+	if c.tokenCacheFile != "" {
+		if err := c.saveCachedToken(token); err != nil {
+			c.Warnf("writing token cache %s: %v", c.tokenCacheFile, err)
+		}
+	}
+	return token, nil
+}
+
+// Close stops the background token refresh (see WithAutoRefreshBefore) and waits until it has stopped.
+// It does not wait for in-flight requests, see Shutdown.
+func (c *Client) Close() error {
+	c.closeOnce.Do(func() {
+		if c.refreshStop != nil {
+			close(c.refreshStop)
+			<-c.refreshDone
+		}
+	})
+	return nil
+}
@@ -0,0 +1,64 @@
+package infosight
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestWithAutoRefreshBefore(t *testing.T) {
+	ts := newTestServer(t, issuesHandler(`[]`))
+	ts.expiresIn = 1
+	c, err := NewClient(ts.URL, WithAutoRefreshBefore(600*time.Millisecond), WithExpiryDelta(100*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the first token is fetched right away, the second one before the first expires
+	deadline := time.Now().Add(3 * time.Second)
+	for ts.Tokens() < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if ts.Tokens() < 2 {
+		t.Fatalf("expected the token to be refreshed in the background, got %d tokens", ts.Tokens())
+	}
+	tokens := ts.Tokens()
+	if _, err := c.Wellness.GetIssues(); err != nil {
+		t.Fatal(err)
+	}
+	if ts.Tokens() != tokens {
+		t.Error("expected the request to use the refreshed token")
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	tokens = ts.Tokens()
+	time.Sleep(700 * time.Millisecond)
+	if ts.Tokens() != tokens {
+		t.Errorf("expected no refresh after Close, got %d more tokens", ts.Tokens()-tokens)
+	}
+	if err := c.Close(); err != nil {
+		t.Errorf("expected Close to be idempotent, got %v", err)
+	}
+
+	if _, err := NewClient(ts.URL, WithAutoRefreshBefore(0)); err == nil {
+		t.Error("expected an error for a zero duration")
+	}
+}
+
+func TestWithAutoRefreshBeforeContext(t *testing.T) {
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {})
+	ctx, cancel := context.WithCancel(context.Background())
+	c, err := NewClient(ts.URL, WithContext(ctx), WithAutoRefreshBefore(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	select {
+	case <-c.refreshDone:
+	case <-time.After(time.Second):
+		t.Fatal("expected the background refresh to stop with the client context")
+	}
+}
@@ -0,0 +1,167 @@
+package infosight
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// Exchange is a recorded request and its response, RecordingDoer writes them as JSON lines
+type Exchange struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+// key identifies the request of an exchange independent of the server it was sent to
+func exchangeKey(method string, requestURI string) string {
+	return method + " " + requestURI
+}
+
+// RecordingDoer passes requests to another doer and writes every exchange as JSON line, to be replayed by ReplayDoer
+type RecordingDoer struct {
+	doer HTTPRequestDoer
+
+	mu      *sync.Mutex
+	encoder *json.Encoder
+}
+
+// NewRecordingDoer records the exchanges of doer to w
+func NewRecordingDoer(doer HTTPRequestDoer, w io.Writer) *RecordingDoer {
+	return &RecordingDoer{
+		doer:    doer,
+		mu:      &sync.Mutex{},
+		encoder: json.NewEncoder(w),
+	}
+}
+
+// wrap returns a RecordingDoer for doer writing to the same recording as d
+func (d *RecordingDoer) wrap(doer HTTPRequestDoer) *RecordingDoer {
+	return &RecordingDoer{
+		doer:    doer,
+		mu:      d.mu,
+		encoder: d.encoder,
+	}
+}
+
+// Do performs the request and records the exchange
+func (d *RecordingDoer) Do(req *http.Request) (*http.Response, error) {
+	r, err := d.doer.Do(req)
+	if err != nil {
+		return r, err
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	err = d.encoder.Encode(&Exchange{
+		Method: req.Method,
+		URL:    req.URL.String(),
+		Status: r.StatusCode,
+		Header: r.Header,
+		Body:   string(body),
+	})
+	return r, err
+}
+
+// roundTripperDoer performs requests with a RoundTripper
+type roundTripperDoer struct {
+	rt http.RoundTripper
+}
+
+func (d roundTripperDoer) Do(req *http.Request) (*http.Response, error) {
+	return d.rt.RoundTrip(req)
+}
+
+// recordingTransport records the exchanges of a RoundTripper, see WithRecorder
+type recordingTransport struct {
+	*RecordingDoer
+}
+
+// RoundTrip performs the request and records the exchange
+func (t recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.Do(req)
+}
+
+// ReplayDoer serves recorded responses without network access. Requests are matched by method, path and query,
+// so a recording can be replayed against any server url. Multiple exchanges for the same request are
+// replayed in order, the last one is repeated.
+type ReplayDoer struct {
+	mu        sync.Mutex
+	exchanges map[string][]*Exchange
+}
+
+// NewReplayDoer reads exchanges recorded by RecordingDoer
+func NewReplayDoer(r io.Reader) (*ReplayDoer, error) {
+	d := &ReplayDoer{exchanges: map[string][]*Exchange{}}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 64<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var exchange Exchange
+		if err := json.Unmarshal(scanner.Bytes(), &exchange); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		req, err := http.NewRequest(exchange.Method, exchange.URL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		key := exchangeKey(req.Method, req.URL.RequestURI())
+		d.exchanges[key] = append(d.exchanges[key], &exchange)
+	}
+	return d, scanner.Err()
+}
+
+// NewReplayDoerFromFile reads exchanges from a file written by RecordingDoer
+func NewReplayDoerFromFile(path string) (*ReplayDoer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return NewReplayDoer(f)
+}
+
+// Do returns the recorded response of the request
+func (d *ReplayDoer) Do(req *http.Request) (*http.Response, error) {
+	key := exchangeKey(req.Method, req.URL.RequestURI())
+
+	d.mu.Lock()
+	exchanges := d.exchanges[key]
+	if len(exchanges) == 0 {
+		d.mu.Unlock()
+		return nil, fmt.Errorf("no recorded response for %s", key)
+	}
+	exchange := exchanges[0]
+	if len(exchanges) > 1 {
+		d.exchanges[key] = exchanges[1:]
+	}
+	d.mu.Unlock()
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", exchange.Status, http.StatusText(exchange.Status)),
+		StatusCode:    exchange.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        exchange.Header.Clone(),
+		Body:          ioutil.NopCloser(bytes.NewReader([]byte(exchange.Body))),
+		ContentLength: int64(len(exchange.Body)),
+		Request:       req,
+	}, nil
+}
@@ -0,0 +1,90 @@
+package infosight
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRecordReplay(t *testing.T) {
+	ts := newTestServer(t, issuesHandler(issuesFixture))
+
+	var recording bytes.Buffer
+	c, err := NewClient(ts.URL, WithHTTPClient(NewRecordingDoer(http.DefaultClient, &recording)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorded, err := GetPage[Issue](c.Wellness, context.Background(), "issues")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(recording.String(), "\n"); n != 1 {
+		t.Fatalf("expected 1 recorded exchange, got %d", n)
+	}
+	ts.Close()
+
+	replay, err := NewReplayDoer(&recording)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err = NewClient("https://replay.example.com/", WithHTTPClient(replay))
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayed, err := GetPage[Issue](c.Wellness, context.Background(), "issues")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(replayed.Items) != len(recorded.Items) || replayed.Items[0].UUID != recorded.Items[0].UUID {
+		t.Fatalf("replayed %+v, recorded %+v", replayed.Items, recorded.Items)
+	}
+
+	if _, err := c.Wellness.GetObjectSetContext(context.Background(), "issues", WithFilter("status.value", "open")); err == nil {
+		t.Fatal("expected error for unrecorded request")
+	}
+}
+
+func TestWithRecorder(t *testing.T) {
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		issuesHandler(issuesFixture)(w, r)
+	})
+
+	var recording bytes.Buffer
+	c, err := NewClient(ts.URL, WithRecorder(&recording))
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorded, err := GetPage[Issue](c.Wellness, context.Background(), "issues")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(recording.String(), "\n"); n != 1 {
+		t.Fatalf("expected 1 recorded exchange without the token request, got %d", n)
+	}
+	if strings.Contains(recording.String(), "token-1") {
+		t.Fatal("expected the recording not to contain the token")
+	}
+	ts.Close()
+
+	replay, err := NewReplayDoer(&recording)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err = NewClient("https://replay.example.com/", WithHTTPClient(replay))
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayed, err := GetPage[Issue](c.Wellness, context.Background(), "issues")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(replayed.Items) != len(recorded.Items) || replayed.Items[0].UUID != recorded.Items[0].UUID {
+		t.Fatalf("replayed %+v, recorded %+v", replayed.Items, recorded.Items)
+	}
+}
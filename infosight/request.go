@@ -0,0 +1,234 @@
+package infosight
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// requestOptions collects the parameters of a single request
+type requestOptions struct {
+	query url.Values
+	// progress is called by iterators after every page
+	progress func(fetched int, total int)
+	// clampedLimit is the requested limit if it was reduced to the maximum page size
+	clampedLimit string
+	// dedupeKey is the field iterators dedupe objects by
+	dedupeKey string
+	// userAgent replaces the user agent of the client for this request
+	userAgent string
+	// filters are the query parameters set by WithFilter and WithFilterInfo
+	filters map[string]bool
+}
+
+// addFilter sets the query parameter of a filter
+func (o *requestOptions) addFilter(field string, value string) {
+	o.query.Set(field, value)
+	if o.filters == nil {
+		o.filters = map[string]bool{}
+	}
+	o.filters[field] = true
+}
+
+// RequestOption allows setting custom parameters for a single request
+type RequestOption func(*requestOptions) error
+
+// WithPaging requests limit objects starting after the first skip objects
+func WithPaging(skip int, limit int) RequestOption {
+	return func(o *requestOptions) error {
+		if skip < 0 || limit < 0 {
+			return fmt.Errorf("invalid paging skip=%d limit=%d", skip, limit)
+		}
+		o.query.Set("skip", strconv.Itoa(skip))
+		o.query.Set("limit", strconv.Itoa(limit))
+		return nil
+	}
+}
+
+// WithFilter restricts the result to objects where field equals value, e.g. WithFilter("condition.severity", "critical")
+func WithFilter(field string, value string) RequestOption {
+	return func(o *requestOptions) error {
+		if field == "" {
+			return errors.New("empty filter field")
+		}
+		o.addFilter(field, value)
+		return nil
+	}
+}
+
+// WithFilterInfo restricts the result to objects matching all fields of the filter, see BuildFilter
+func WithFilterInfo(filter *FilterInfo) RequestOption {
+	return func(o *requestOptions) error {
+		if filter == nil {
+			return nil
+		}
+		for field, value := range filter.Query {
+			if field == "" {
+				return errors.New("empty filter field")
+			}
+			o.addFilter(field, value)
+		}
+		return nil
+	}
+}
+
+// WithSort orders the result, each order is a field name followed by asc or desc, e.g. WithSort("condition.severity asc", "status.timestamp desc")
+func WithSort(order ...string) RequestOption {
+	return func(o *requestOptions) error {
+		o.query.Set("sort", strings.Join(order, ", "))
+		return nil
+	}
+}
+
+// WithFields requests only the given fields of the objects, e.g. WithFields("uuid", "condition.severity")
+func WithFields(fields ...string) RequestOption {
+	return func(o *requestOptions) error {
+		if len(fields) == 0 {
+			return errors.New("no fields requested")
+		}
+		for _, field := range fields {
+			if strings.TrimSpace(field) == "" || strings.Contains(field, ",") {
+				return fmt.Errorf("invalid field name %q", field)
+			}
+		}
+		o.query.Set("fields", strings.Join(fields, ","))
+		return nil
+	}
+}
+
+// WithQueryParam adds an arbitrary query parameter, e.g. for parameters not modeled by this client.
+// Adding the same key multiple times sends all values.
+func WithQueryParam(key string, value string) RequestOption {
+	return func(o *requestOptions) error {
+		if key == "" {
+			return errors.New("empty query parameter name")
+		}
+		o.query.Add(key, value)
+		return nil
+	}
+}
+
+// WithTimeRange restricts the result to objects with a timestamp between start and end (start_time and end_time),
+// a zero start or end leaves the range open. end is ignored by the server for sessions (see WithSession).
+func WithTimeRange(start time.Time, end time.Time) RequestOption {
+	return func(o *requestOptions) error {
+		if !start.IsZero() && !end.IsZero() && end.Before(start) {
+			return fmt.Errorf("invalid time range %v - %v", start, end)
+		}
+		if !start.IsZero() {
+			o.query.Set("start_time", start.UTC().Format(timeFormat))
+		}
+		if !end.IsZero() {
+			o.query.Set("end_time", end.UTC().Format(timeFormat))
+		}
+		return nil
+	}
+}
+
+// withoutFilters removes the filters set by the preceding options, e.g. when polling a session which applies them
+func withoutFilters() RequestOption {
+	return func(o *requestOptions) error {
+		for field := range o.filters {
+			o.query.Del(field)
+		}
+		o.filters = nil
+		return nil
+	}
+}
+
+// WithSession asks the server to create a polling session, its id is returned in the SessionInfo of the response status
+func WithSession() RequestOption {
+	return func(o *requestOptions) error {
+		o.query.Set("session_enabled", "true")
+		return nil
+	}
+}
+
+// WithSessionID polls a session created by WithSession. The server only returns objects
+// which are new since the last poll and applies the filters of the session.
+func WithSessionID(id string) RequestOption {
+	return func(o *requestOptions) error {
+		if id == "" {
+			return errors.New("empty session id")
+		}
+		o.query.Set("session_id", id)
+		return nil
+	}
+}
+
+// WithProgress calls fn after every page fetched by an iterator or export with the number of objects
+// fetched so far and the total number of objects reported by the server (0 if unknown)
+func WithProgress(fn func(fetched int, total int)) RequestOption {
+	return func(o *requestOptions) error {
+		o.progress = fn
+		return nil
+	}
+}
+
+// WithDedupeKey lets iterators skip objects whose key field (e.g. _id) was already returned, as offset based paging
+// returns objects twice if the object set changes during the iteration. Only the keys of the last 100000 objects are
+// remembered to bound the memory, duplicates further apart are not detected. Objects without the key are kept.
+func WithDedupeKey(key string) RequestOption {
+	return func(o *requestOptions) error {
+		if key == "" {
+			return errors.New("empty dedupe key")
+		}
+		o.dedupeKey = key
+		return nil
+	}
+}
+
+// WithCallUserAgent sends ua as user agent of this request instead of the user agent of the client (see WithUserAgent),
+// e.g. to tell nightly exports and dashboards apart in the InfoSight logs
+func WithCallUserAgent(ua string) RequestOption {
+	return func(o *requestOptions) error {
+		if strings.TrimSpace(ua) == "" {
+			return errors.New("empty user agent")
+		}
+		o.userAgent = ua
+		return nil
+	}
+}
+
+// singleValueParams are the query parameters of the options which must not be sent more than once
+var singleValueParams = []string{"skip", "limit", "sort", "fields", "start_time", "end_time", "session_id"}
+
+// ValidateRequest checks a request of objectSet with opts for invalid options and conflicting parameters without
+// sending it, e.g. in unit tests. The defaults of the object set and the client are not taken into account.
+func ValidateRequest(objectSet string, opts ...RequestOption) error {
+	if err := validateObjectSetName(objectSet); err != nil {
+		return err
+	}
+	o := &requestOptions{query: url.Values{}}
+	for _, opt := range opts {
+		if err := opt(o); err != nil {
+			return err
+		}
+	}
+
+	for _, param := range singleValueParams {
+		if values := o.query[param]; len(values) > 1 {
+			return fmt.Errorf("conflicting values %q for %s", values, param)
+		}
+	}
+	if sort := o.query.Get("sort"); sort != "" {
+		for _, order := range strings.Split(sort, ",") {
+			fields := strings.Fields(order)
+			if len(fields) == 0 || len(fields) > 2 || (len(fields) == 2 && fields[1] != "asc" && fields[1] != "desc") {
+				return fmt.Errorf("invalid sort order %q, expected a field name followed by asc or desc", strings.TrimSpace(order))
+			}
+		}
+	}
+	if o.query.Get("session_id") != "" {
+		if o.query.Get("session_enabled") != "" {
+			return errors.New("a session can not be created (WithSession) while polling one (WithSessionID)")
+		}
+		if o.query.Get("end_time") != "" {
+			return errors.New("the end of the time range is ignored when polling a session")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package infosight
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// BackoffStrategy determines the delay before a retry, attempt is 1 for the first retry
+type BackoffStrategy interface {
+	NextDelay(attempt int) time.Duration
+}
+
+// ConstantBackoff waits Delay before every retry
+type ConstantBackoff struct {
+	Delay time.Duration
+}
+
+// NextDelay returns Delay
+func (b ConstantBackoff) NextDelay(attempt int) time.Duration {
+	return b.Delay
+}
+
+// LinearBackoff waits attempt times Delay
+type LinearBackoff struct {
+	Delay time.Duration
+}
+
+// NextDelay returns attempt times Delay
+func (b LinearBackoff) NextDelay(attempt int) time.Duration {
+	return time.Duration(attempt) * b.Delay
+}
+
+// ExponentialBackoff doubles the delay for every retry starting with Delay, up to Max (if set)
+type ExponentialBackoff struct {
+	Delay time.Duration
+	Max   time.Duration
+}
+
+// NextDelay returns Delay * 2^(attempt-1), capped at Max
+func (b ExponentialBackoff) NextDelay(attempt int) time.Duration {
+	delay := b.Delay
+	for i := 1; i < attempt && (b.Max <= 0 || delay < b.Max); i++ {
+		delay *= 2
+	}
+	if b.Max > 0 && delay > b.Max {
+		delay = b.Max
+	}
+	return delay
+}
+
+// ExponentialJitterBackoff is an ExponentialBackoff randomly shortened by up to half of the delay,
+// so clients failing at the same time do not retry in lockstep
+type ExponentialJitterBackoff struct {
+	Delay time.Duration
+	Max   time.Duration
+}
+
+// NextDelay returns a random delay between half and all of the ExponentialBackoff delay
+func (b ExponentialJitterBackoff) NextDelay(attempt int) time.Duration {
+	delay := ExponentialBackoff{Delay: b.Delay, Max: b.Max}.NextDelay(attempt)
+	if delay <= 1 {
+		return delay
+	}
+	return delay - time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// WithBackoffStrategy sets the delays between retries (see WithRetries), by default ExponentialJitterBackoff
+func WithBackoffStrategy(b BackoffStrategy) ClientOption {
+	return func(c *Client) error {
+		if b == nil {
+			return errors.New("no backoff strategy")
+		}
+		c.backoff = b
+		return nil
+	}
+}
+
+// WithRetries retries requests failing with a retryable ConnectionError, a 5xx status or 429 Too Many Requests
+// up to retries times. By default the delay before the first retry is doubled for every further retry,
+// shortened by a random jitter (see WithBackoffStrategy). Every retry is logged as warning, giving up as error.
+func WithRetries(retries int, delay time.Duration) ClientOption {
+	return func(c *Client) error {
+		if retries < 0 || delay < 0 {
+			return fmt.Errorf("invalid retries %d with delay %v", retries, delay)
+		}
+		c.retries = retries
+		c.retryDelay = delay
+		return nil
+	}
+}
+
+// WithRetryPredicate retries requests for which predicate returns true in addition to the default classification of
+// WithRetries, e.g. faults with a specific error code (see NewFaultResponse). resp is nil if err is set. The predicate
+// may read the body of resp, it is restored afterwards. Retries use the attempts and backoff of WithRetries.
+func WithRetryPredicate(predicate func(resp *http.Response, err error) bool) ClientOption {
+	return func(c *Client) error {
+		if predicate == nil {
+			return errors.New("nil retry predicate")
+		}
+		c.retryPredicate = predicate
+		return nil
+	}
+}
+
+// checkRetryPredicate calls the retry predicate of the client with a copy of the response body
+func (c *Client) checkRetryPredicate(r *http.Response, err error) (bool, error) {
+	if r == nil {
+		return c.retryPredicate(nil, err), nil
+	}
+	raw, readErr := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	if readErr != nil {
+		return false, readErr
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(raw))
+	retry := c.retryPredicate(r, err)
+	r.Body = ioutil.NopCloser(bytes.NewReader(raw))
+	return retry, nil
+}
+
+// sendWithRetries sends the request including failover and retries it if it failed transiently.
+// It returns the request which was sent last.
+func (c *Client) sendWithRetries(req *http.Request) (*http.Request, *http.Response, error) {
+	backoff := c.backoff
+	if backoff == nil {
+		backoff = ExponentialJitterBackoff{Delay: c.retryDelay}
+	}
+	attempts := c.retries + 1
+	for attempt := 1; ; attempt++ {
+		if c.rateLimiter != nil {
+			if err := c.rateLimiter.WaitN(req.Context(), c.rateLimitWeight); err != nil {
+				return req, nil, err
+			}
+		}
+		sent, r, err := c.send(req)
+		err = connectionError(sent, err)
+		retry := needsRetry(sent, r, err)
+		if !retry && c.retryPredicate != nil {
+			var readErr error
+			if retry, readErr = c.checkRetryPredicate(r, err); readErr != nil {
+				return sent, nil, readErr
+			}
+		}
+		if handler, ok := c.statusHandler(r); ok {
+			if retry, err = handler(r); err != nil {
+				r.Body.Close()
+				return sent, nil, err
+			}
+		}
+		if !retry {
+			return sent, r, err
+		}
+		cause := ""
+		if r != nil {
+			cause = r.Status
+		} else {
+			cause = err.Error()
+		}
+		if attempt >= attempts {
+			if c.retries > 0 {
+				c.Errorf("%s %s failed after %d attempts: %s", req.Method, req.URL.Redacted(), attempt, cause)
+			}
+			return sent, r, err
+		}
+
+		delay := backoff.NextDelay(attempt)
+		c.Warnf("%s %s failed (attempt %d/%d), retrying in %v: %s", req.Method, req.URL.Redacted(), attempt, attempts, delay, cause)
+		if r != nil {
+			r.Body.Close()
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return sent, nil, req.Context().Err()
+		case <-timer.C:
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return sent, nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// needsRetry reports whether a request failed in a way a later attempt might not
+func needsRetry(req *http.Request, r *http.Response, err error) bool {
+	if err != nil {
+		var connErr *ConnectionError
+		return errors.As(err, &connErr) && connErr.IsRetryable()
+	}
+	return r.StatusCode >= 500 || r.StatusCode == http.StatusTooManyRequests
+}
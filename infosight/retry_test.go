@@ -0,0 +1,138 @@
+package infosight
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithRetries(t *testing.T) {
+	var requests int32
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		issuesHandler(`[]`)(w, r)
+	})
+	c, err := NewClient(ts.URL, WithRetries(3, time.Millisecond), WithBackoffStrategy(ExponentialBackoff{Delay: time.Millisecond}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logs := captureLog(t)
+	if _, err := c.Wellness.GetIssues(); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 retry log lines, got %q", lines)
+	}
+	for i, expected := range []string{"(attempt 1/4), retrying in 1ms: 503", "(attempt 2/4), retrying in 2ms: 503"} {
+		if !strings.HasPrefix(lines[i], "[WARN] GET ") || !strings.Contains(lines[i], expected) {
+			t.Errorf("expected log line %d to contain %q, got %q", i, expected, lines[i])
+		}
+	}
+}
+
+func TestWithRetriesExhausted(t *testing.T) {
+	var requests int32
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+	c, err := NewClient(ts.URL, WithRetries(2, time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logs := captureLog(t)
+	_, err = c.Wellness.GetIssues()
+	if f, ok := err.(*FaultResponse); !ok || f.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected a 429 fault, got %v", err)
+	}
+	if n := atomic.LoadInt32(&requests); n != 3 {
+		t.Errorf("expected 3 attempts, got %d", n)
+	}
+	if !strings.Contains(logs.String(), "[ERROR] GET ") || !strings.Contains(logs.String(), "failed after 3 attempts: 429") {
+		t.Errorf("expected the exhausted attempts to be logged, got %q", logs.String())
+	}
+}
+
+func TestWithRetryPredicate(t *testing.T) {
+	var requests int32
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) <= 2 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			fmt.Fprint(w, `{"fault":{"faultstring":"index rebuilding","detail":{"errorcode":"index.busy"}}}`)
+			return
+		}
+		issuesHandler(`[]`)(w, r)
+	})
+	retryBusy := func(resp *http.Response, err error) bool {
+		if resp == nil || resp.StatusCode != http.StatusConflict {
+			return false
+		}
+		fault, err := NewFaultResponse(resp)
+		return err == nil && fault.Fault != nil && fault.Fault.Detail != nil && fault.Fault.Detail.ErrorCode == "index.busy"
+	}
+	c, err := NewClient(ts.URL, WithRetries(3, time.Millisecond), WithRetryPredicate(retryBusy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Wellness.GetIssues(); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&requests); n != 3 {
+		t.Errorf("expected the 409 to be retried, got %d attempts", n)
+	}
+
+	// without the predicate the 409 fault is returned with its body
+	atomic.StoreInt32(&requests, 0)
+	c, err = NewClient(ts.URL, WithRetries(3, time.Millisecond), WithRetryPredicate(func(*http.Response, error) bool { return false }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.Wellness.GetIssues()
+	if f, ok := err.(*FaultResponse); !ok || f.Fault == nil || f.Fault.Detail == nil || f.Fault.Detail.ErrorCode != "index.busy" {
+		t.Errorf("expected the 409 fault, got %v", err)
+	}
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("expected a single attempt, got %d", n)
+	}
+}
+
+func TestBackoffStrategies(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy BackoffStrategy
+		expected []time.Duration
+	}{
+		{"constant", ConstantBackoff{Delay: time.Second}, []time.Duration{time.Second, time.Second, time.Second, time.Second}},
+		{"linear", LinearBackoff{Delay: time.Second}, []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 4 * time.Second}},
+		{"exponential", ExponentialBackoff{Delay: time.Second}, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second}},
+		{"exponential capped", ExponentialBackoff{Delay: time.Second, Max: 3 * time.Second}, []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i, expected := range tt.expected {
+				if delay := tt.strategy.NextDelay(i + 1); delay != expected {
+					t.Errorf("attempt %d: expected %v, got %v", i+1, expected, delay)
+				}
+			}
+		})
+	}
+
+	jitter := ExponentialJitterBackoff{Delay: time.Second, Max: 4 * time.Second}
+	for i, max := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second} {
+		for n := 0; n < 100; n++ {
+			if delay := jitter.NextDelay(i + 1); delay < max/2 || delay > max {
+				t.Fatalf("attempt %d: expected a delay between %v and %v, got %v", i+1, max/2, max, delay)
+			}
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package infosight
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// SelfTestStep is the result of a single step of SelfTest
+type SelfTestStep struct {
+	Name     string        `json:"name"`
+	OK       bool          `json:"ok"`
+	Skipped  bool          `json:"skipped,omitempty"`
+	Duration time.Duration `json:"duration"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// SelfTestReport lists the steps of SelfTest in the order they were run
+type SelfTestReport struct {
+	Server string         `json:"server"`
+	Steps  []SelfTestStep `json:"steps"`
+}
+
+// OK reports whether all steps succeeded or were skipped
+func (r SelfTestReport) OK() bool {
+	for _, step := range r.Steps {
+		if !step.OK && !step.Skipped {
+			return false
+		}
+	}
+	return true
+}
+
+// SelfTest checks the DNS resolution of the server host, the TLS handshake (https only), the token acquisition
+// (skipped with WithAPIKey) and a minimal API call. All steps are run even if earlier ones fail, the returned error
+// lists the failed steps. It is meant for troubleshooting, e.g. to print the report when a connection "does not work".
+func (c *Client) SelfTest(ctx context.Context) (SelfTestReport, error) {
+	report := SelfTestReport{Server: c.Server}
+	u, err := url.Parse(c.Server)
+	if err != nil {
+		return report, err
+	}
+	port := u.Port()
+	if port == "" {
+		port = "443"
+		if u.Scheme == "http" {
+			port = "80"
+		}
+	}
+
+	run := func(name string, skip bool, fn func() error) {
+		step := SelfTestStep{Name: name, Skipped: skip}
+		if !skip {
+			start := time.Now()
+			err := fn()
+			step.Duration = time.Since(start)
+			step.OK = err == nil
+			if err != nil {
+				step.Error = err.Error()
+			}
+		}
+		report.Steps = append(report.Steps, step)
+	}
+
+	run("dns", false, func() error {
+		_, err := net.DefaultResolver.LookupHost(ctx, u.Hostname())
+		return err
+	})
+	run("tls", u.Scheme != "https", func() error {
+		dialer := &tls.Dialer{Config: &tls.Config{ServerName: u.Hostname(), InsecureSkipVerify: c.insecure}}
+		conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(u.Hostname(), port))
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	})
+	run("token", c.apiKeyHeader != "", func() error {
+		_, err := c.Token()
+		return err
+	})
+	run("api", false, func() error {
+		_, err := c.Wellness.GetObjectSetContext(ctx, "issues", WithPaging(0, 1), WithFields("_id"))
+		return err
+	})
+
+	failed := []string{}
+	for _, step := range report.Steps {
+		if !step.OK && !step.Skipped {
+			failed = append(failed, step.Name)
+		}
+	}
+	if len(failed) > 0 {
+		return report, fmt.Errorf("self test of %s failed: %s", u.Host, strings.Join(failed, ", "))
+	}
+	return report, nil
+}
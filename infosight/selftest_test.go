@@ -0,0 +1,62 @@
+package infosight
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSelfTest(t *testing.T) {
+	ts := newTestServer(t, issuesHandler(`[]`))
+	ts.tokenFailures = []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusUnauthorized, http.StatusUnauthorized}
+	c, err := NewClient(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := c.SelfTest(context.Background())
+	if err == nil {
+		t.Fatal("expected the self test to fail")
+	}
+	if report.OK() {
+		t.Error("expected the report to fail")
+	}
+	expected := []struct {
+		name    string
+		ok      bool
+		skipped bool
+	}{{"dns", true, false}, {"tls", false, true}, {"token", false, false}, {"api", false, false}}
+	if len(report.Steps) != len(expected) {
+		t.Fatalf("expected %d steps, got %+v", len(expected), report.Steps)
+	}
+	for i, step := range report.Steps {
+		if step.Name != expected[i].name || step.OK != expected[i].ok || step.Skipped != expected[i].skipped {
+			t.Errorf("unexpected step %d: %+v", i, step)
+		}
+		if !step.OK && !step.Skipped && step.Error == "" {
+			t.Errorf("expected the error of step %s", step.Name)
+		}
+	}
+
+}
+
+func TestSelfTestTLS(t *testing.T) {
+	delay := tokenRetryDelay
+	tokenRetryDelay = time.Millisecond
+	t.Cleanup(func() { tokenRetryDelay = delay })
+
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+	c, err := NewClient(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the certificate of the test server is not trusted
+	report, _ := c.SelfTest(context.Background())
+	if len(report.Steps) < 2 || report.Steps[1].Name != "tls" || report.Steps[1].OK || report.Steps[1].Error == "" {
+		t.Errorf("expected the TLS handshake to fail, got %+v", report.Steps)
+	}
+}
@@ -0,0 +1,91 @@
+package infosight
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+)
+
+// ErrShutdown is returned for requests issued after Shutdown was called
+var ErrShutdown = errors.New("infosight: client is shut down")
+
+// requestTracker keeps track of the in-flight requests of a client
+type requestTracker struct {
+	mu       sync.Mutex
+	wg       sync.WaitGroup
+	shutdown bool
+	nextID   uint64
+	inFlight map[uint64]context.CancelFunc
+}
+
+// start registers a new in-flight request. The returned context is canceled if Shutdown times out,
+// done must be called once the request has finished.
+func (t *requestTracker) start(ctx context.Context) (context.Context, func(), error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.shutdown {
+		return nil, nil, ErrShutdown
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	t.nextID++
+	id := t.nextID
+	if t.inFlight == nil {
+		t.inFlight = map[uint64]context.CancelFunc{}
+	}
+	t.inFlight[id] = cancel
+	t.wg.Add(1)
+
+	var once sync.Once
+	done := func() {
+		once.Do(func() {
+			t.mu.Lock()
+			delete(t.inFlight, id)
+			t.mu.Unlock()
+			cancel()
+			t.wg.Done()
+		})
+	}
+	return ctx, done, nil
+}
+
+// trackedBody finishes an in-flight request once its response body is closed
+type trackedBody struct {
+	io.ReadCloser
+	done func()
+}
+
+// Close closes the body and finishes the request
+func (b *trackedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.done()
+	return err
+}
+
+// Shutdown rejects new requests and waits for the in-flight requests to finish (i.e. their response
+// bodies to be closed). If ctx is done before, the remaining requests are canceled and the context
+// error is returned.
+func (c *Client) Shutdown(ctx context.Context) error {
+	c.requests.mu.Lock()
+	c.requests.shutdown = true
+	c.requests.mu.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		c.requests.wg.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		c.requests.mu.Lock()
+		for _, cancel := range c.requests.inFlight {
+			cancel()
+		}
+		c.requests.mu.Unlock()
+		return ctx.Err()
+	}
+}
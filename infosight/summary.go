@@ -0,0 +1,59 @@
+package infosight
+
+import "context"
+
+// unknownSeverity is the severity of issues without condition severity in a DeviceHealthSummary
+const unknownSeverity = "unknown"
+
+// DeviceHealth counts the issues of a device (system)
+type DeviceHealth struct {
+	System     AffectedSystem
+	Issues     int
+	BySeverity map[string]int
+}
+
+// DeviceHealthSummary is an overview of the issues of a fleet, see GetDeviceHealthSummary
+type DeviceHealthSummary struct {
+	// Issues is the number of issues, including issues not affecting any known device
+	Issues     int
+	BySeverity map[string]int
+	// Devices by serial number (or urn if the serial number is unknown)
+	Devices map[string]*DeviceHealth
+}
+
+// GetDeviceHealthSummary fetches all issues matching opts (e.g. WithFilter("status.value", "open")) and counts
+// them per severity and device. The wellness API has no recommendations, so only issues are counted.
+func (w *Wellness) GetDeviceHealthSummary(ctx context.Context, opts ...RequestOption) (DeviceHealthSummary, error) {
+	summary := DeviceHealthSummary{
+		BySeverity: map[string]int{},
+		Devices:    map[string]*DeviceHealth{},
+	}
+	issues, err := w.GetAllIssues(ctx, opts...)
+	if err != nil {
+		return summary, err
+	}
+
+	for _, issue := range issues {
+		severity := unknownSeverity
+		if issue.Condition != nil && issue.Condition.Severity != "" {
+			severity = issue.Condition.Severity
+		}
+		summary.Issues++
+		summary.BySeverity[severity]++
+
+		for _, system := range issue.Systems() {
+			key := system.Serial
+			if key == "" {
+				key = system.URN
+			}
+			device, ok := summary.Devices[key]
+			if !ok {
+				device = &DeviceHealth{System: system, BySeverity: map[string]int{}}
+				summary.Devices[key] = device
+			}
+			device.Issues++
+			device.BySeverity[severity]++
+		}
+	}
+	return summary, nil
+}
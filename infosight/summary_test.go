@@ -0,0 +1,43 @@
+package infosight
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestGetDeviceHealthSummary(t *testing.T) {
+	ts := newTestServer(t, issuesHandler(`[
+		{"_id": "1", "condition": {"severity": "critical"}, "asset": {"urn": "urn:nimble:array:AF-1", "name": "san1"}},
+		{"_id": "2", "condition": {"severity": "warning"}, "asset": {"urn": "urn:nimble:array:AF-1", "name": "san1"}},
+		{"_id": "3", "condition": {"severity": "critical"}, "systems": [
+			{"urn": "urn:nimble:array:AF-1", "name": "san1"},
+			{"urn": "urn:nimble:array:AF-2", "name": "san2"}]},
+		{"_id": "4", "title": "no device"}
+	]`))
+	c, err := NewClient(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	summary, err := c.Wellness.GetDeviceHealthSummary(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Issues != 4 {
+		t.Errorf("expected 4 issues, got %d", summary.Issues)
+	}
+	if expected := map[string]int{"critical": 2, "warning": 1, "unknown": 1}; !reflect.DeepEqual(summary.BySeverity, expected) {
+		t.Errorf("expected severities %v, got %v", expected, summary.BySeverity)
+	}
+	if len(summary.Devices) != 2 {
+		t.Fatalf("expected 2 devices, got %d", len(summary.Devices))
+	}
+	san1 := summary.Devices["AF-1"]
+	if san1.System.Name != "san1" || san1.Issues != 3 || !reflect.DeepEqual(san1.BySeverity, map[string]int{"critical": 2, "warning": 1}) {
+		t.Errorf("unexpected health of AF-1 %+v", san1)
+	}
+	if san2 := summary.Devices["AF-2"]; san2.Issues != 1 || san2.BySeverity["critical"] != 1 {
+		t.Errorf("unexpected health of AF-2 %+v", san2)
+	}
+}
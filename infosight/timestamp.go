@@ -0,0 +1,67 @@
+package infosight
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// epochMillisThreshold separates epoch seconds from epoch milliseconds, as seconds it is in the year 5138
+const epochMillisThreshold = 1e11
+
+// Timestamp is a point in time sent by InfoSight either as RFC3339 string or as epoch seconds or milliseconds
+type Timestamp struct {
+	time.Time
+}
+
+// UnmarshalJSON decodes RFC3339 strings as well as epoch seconds and milliseconds (as number or string)
+func (t *Timestamp) UnmarshalJSON(data []byte) error {
+	raw := strings.TrimSpace(string(data))
+	if raw == "null" {
+		t.Time = time.Time{}
+		return nil
+	}
+
+	if strings.HasPrefix(raw, `"`) {
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return err
+		}
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			t.Time = time.Time{}
+			return nil
+		}
+		if parsed, err := time.Parse(time.RFC3339Nano, raw); err == nil {
+			t.Time = parsed
+			return nil
+		}
+	}
+
+	epoch, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp %s", data)
+	}
+	t.Time = fromEpoch(epoch)
+	return nil
+}
+
+// MarshalJSON encodes the timestamp in the InfoSight format, zero timestamps as null
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return []byte("null"), nil
+	}
+	return []byte(`"` + t.UTC().Format(timeFormat) + `"`), nil
+}
+
+// fromEpoch converts epoch seconds or milliseconds into a time
+func fromEpoch(epoch float64) time.Time {
+	if math.Abs(epoch) >= epochMillisThreshold {
+		return time.UnixMilli(int64(epoch)).UTC()
+	}
+	sec, frac := math.Modf(epoch)
+	// round to microseconds to get rid of floating point artifacts
+	return time.Unix(int64(sec), int64(math.Round(frac*1e6))*1e3).UTC()
+}
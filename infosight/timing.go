@@ -0,0 +1,60 @@
+package infosight
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WithTiming logs the status, response size and duration of every request once its response body is closed,
+// e.g. "GET wellness/v1/issues -> 200 (1234 bytes, 187ms)". Unlike WithTrace no headers or bodies are logged.
+func WithTiming(enabled bool) ClientOption {
+	return func(c *Client) error {
+		c.timing = enabled
+		return nil
+	}
+}
+
+// timedBody counts the bytes read from a response body and logs the timing line on close
+type timedBody struct {
+	io.ReadCloser
+	n    int64
+	once sync.Once
+	log  func(n int64)
+}
+
+func (b *timedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	return n, err
+}
+
+// Close closes the body and logs the timing line
+func (b *timedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() { b.log(b.n) })
+	return err
+}
+
+// timeResponse logs the timing line of a request sent at start, for responses once their body is closed
+func (c *Client) timeResponse(start time.Time, req *http.Request, r *http.Response, err error) {
+	path := c.relativePath(req.URL)
+	if r == nil {
+		c.Debugf("%s %s -> %v (%dms)", req.Method, path, err, time.Since(start).Milliseconds())
+		return
+	}
+	r.Body = &timedBody{ReadCloser: r.Body, log: func(n int64) {
+		c.Debugf("%s %s -> %d (%d bytes, %dms)", req.Method, path, r.StatusCode, n, time.Since(start).Milliseconds())
+	}}
+}
+
+// relativePath returns the path of u relative to the server url
+func (c *Client) relativePath(u *url.URL) string {
+	if server, err := url.Parse(c.Server); err == nil && u.Host == server.Host {
+		return strings.TrimPrefix(u.Path, server.Path)
+	}
+	return u.Path
+}
@@ -0,0 +1,36 @@
+package infosight
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestWithTiming(t *testing.T) {
+	ts := newTestServer(t, issuesHandler(issuesFixture))
+	c, err := NewClient(ts.URL, WithTiming(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logs := captureLog(t)
+	if _, err := c.Wellness.GetIssues(); err != nil {
+		t.Fatal(err)
+	}
+	line := strings.TrimSpace(logs.String())
+	if !regexp.MustCompile(`^\[DEBUG\] GET wellness/v1/issues -> 200 \([1-9][0-9]* bytes, [0-9]+ms\)$`).MatchString(line) {
+		t.Errorf("unexpected timing line %q", line)
+	}
+
+	c, err = NewClient(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs.Reset()
+	if _, err := c.Wellness.GetIssues(); err != nil {
+		t.Fatal(err)
+	}
+	if logs.Len() != 0 {
+		t.Errorf("expected no timing line by default, got %q", logs.String())
+	}
+}
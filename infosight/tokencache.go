@@ -0,0 +1,85 @@
+package infosight
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// WithTokenCacheFile persists the access token in path (with mode 0600), so it is reused by the next
+// client with the same credentials, e.g. by repeated CLI invocations. Missing, corrupt or expired
+// cache files cause a new authentication.
+func WithTokenCacheFile(path string) ClientOption {
+	return func(c *Client) error {
+		if path == "" {
+			return errors.New("empty token cache file")
+		}
+		c.tokenCacheFile = path
+		return nil
+	}
+}
+
+// cachedToken is the content of a token cache file
+type cachedToken struct {
+	ClientID    string    `json:"client_id"`
+	TokenURL    string    `json:"token_url"`
+	AccessToken string    `json:"access_token"`
+	TokenType   string    `json:"token_type,omitempty"`
+	Expiry      time.Time `json:"expiry,omitempty"`
+}
+
+// loadCachedToken returns the token of the cache file if it was issued to the client, nil otherwise
+func (c *Client) loadCachedToken() *oauth2.Token {
+	raw, err := ioutil.ReadFile(c.tokenCacheFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			c.Debugf("reading token cache %s: %v", c.tokenCacheFile, err)
+		}
+		return nil
+	}
+	var cached cachedToken
+	if err := json.Unmarshal(raw, &cached); err != nil {
+		c.Debugf("ignoring corrupt token cache %s: %v", c.tokenCacheFile, err)
+		return nil
+	}
+	if cached.ClientID != c.oauthConfig.ClientID || cached.TokenURL != c.oauthConfig.TokenURL {
+		return nil
+	}
+	return &oauth2.Token{AccessToken: cached.AccessToken, TokenType: cached.TokenType, Expiry: cached.Expiry}
+}
+
+// saveCachedToken writes token to the cache file, replacing it atomically
+func (c *Client) saveCachedToken(token *oauth2.Token) error {
+	raw, err := json.Marshal(&cachedToken{
+		ClientID:    c.oauthConfig.ClientID,
+		TokenURL:    c.oauthConfig.TokenURL,
+		AccessToken: token.AccessToken,
+		TokenType:   token.TokenType,
+		Expiry:      token.Expiry,
+	})
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(c.tokenCacheFile), filepath.Base(c.tokenCacheFile)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err := f.Chmod(0600); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(raw); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), c.tokenCacheFile)
+}
@@ -0,0 +1,83 @@
+package infosight
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWithTokenCacheFile(t *testing.T) {
+	var auth string
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		issuesHandler(`[]`)(w, r)
+	})
+	path := filepath.Join(t.TempDir(), "token.json")
+
+	get := func(opts ...ClientOption) {
+		t.Helper()
+		c, err := NewClient(ts.URL, append([]ClientOption{WithLogin("key", "secret"), WithTokenCacheFile(path)}, opts...)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.Wellness.GetIssues(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	get()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected mode 0600, got %v", info.Mode().Perm())
+	}
+
+	// a new client reuses the cached token
+	get()
+	if ts.Tokens() != 1 || auth != "Bearer token-1" {
+		t.Errorf("expected the cached token to be reused, got %d token requests (%s)", ts.Tokens(), auth)
+	}
+
+	// the token of other credentials is not used
+	get(WithLogin("other", "secret"))
+	if ts.Tokens() != 2 || auth != "Bearer token-2" {
+		t.Errorf("expected a new token for other credentials, got %d token requests (%s)", ts.Tokens(), auth)
+	}
+
+	// corrupt cache files are replaced
+	if err := ioutil.WriteFile(path, []byte("{corrupt"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	get()
+	if ts.Tokens() != 3 || auth != "Bearer token-3" {
+		t.Errorf("expected a new token for a corrupt cache, got %d token requests (%s)", ts.Tokens(), auth)
+	}
+	get()
+	if ts.Tokens() != 3 {
+		t.Errorf("expected the rewritten cache to be reused, got %d token requests", ts.Tokens())
+	}
+}
+
+func TestWithTokenCacheFileExpired(t *testing.T) {
+	ts := newTestServer(t, issuesHandler(`[]`))
+	ts.expiresIn = 30
+	path := filepath.Join(t.TempDir(), "token.json")
+
+	for i := 0; i < 2; i++ {
+		c, err := NewClient(ts.URL, WithLogin("key", "secret"), WithTokenCacheFile(path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.Wellness.GetIssues(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the token expires within the expiry delta, so it must not be reused
+	if ts.Tokens() != 2 {
+		t.Errorf("expected the expired token to be replaced, got %d token requests", ts.Tokens())
+	}
+}
@@ -0,0 +1,54 @@
+package infosight
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// maxTokenPrefix is the maximum number of characters of the access token revealed by TokenInfo
+const maxTokenPrefix = 6
+
+// TokenInfo describes the current access token without revealing it, see Client.TokenInfo
+type TokenInfo struct {
+	// AccessTokenPrefix are the first characters of the access token (at most half of it) followed by ...
+	AccessTokenPrefix string
+	// TokenType as returned by the token endpoint, InfoSight returns BearerToken instead of Bearer
+	TokenType string
+	// ExpiresAt is zero if the token does not expire
+	ExpiresAt time.Time
+	// Scopes granted by the token endpoint, the requested scopes if it did not report them
+	Scopes []string
+}
+
+// TokenInfo returns information about the current access token for troubleshooting expiry and scope issues,
+// a token is requested if there is no valid one
+func (c *Client) TokenInfo(ctx context.Context) (TokenInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return TokenInfo{}, err
+	}
+	token, err := c.Token()
+	if err != nil {
+		return TokenInfo{}, err
+	}
+
+	info := TokenInfo{
+		AccessTokenPrefix: maskToken(token.AccessToken),
+		TokenType:         token.TokenType,
+		ExpiresAt:         token.Expiry,
+		Scopes:            append([]string{}, c.scopes...),
+	}
+	if scope, ok := token.Extra("scope").(string); ok && scope != "" {
+		info.Scopes = strings.Fields(scope)
+	}
+	return info, nil
+}
+
+// maskToken returns the first characters of token, at most half of it
+func maskToken(token string) string {
+	n := len(token) / 2
+	if n > maxTokenPrefix {
+		n = maxTokenPrefix
+	}
+	return token[:n] + "..."
+}
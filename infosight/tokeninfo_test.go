@@ -0,0 +1,65 @@
+package infosight
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokenInfo(t *testing.T) {
+	ts := newTestServer(t, issuesHandler(`[]`))
+	c, err := NewClient(ts.URL, WithScopes("wellness.read"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := c.TokenInfo(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.AccessTokenPrefix != "tok..." || strings.Contains(info.AccessTokenPrefix, "token-1") {
+		t.Errorf("expected a masked token, got %q", info.AccessTokenPrefix)
+	}
+	if info.TokenType != "BearerToken" {
+		t.Errorf("expected the token type of the server, got %q", info.TokenType)
+	}
+	if until := time.Until(info.ExpiresAt); until < 59*time.Minute || until > time.Hour {
+		t.Errorf("unexpected expiry %v", info.ExpiresAt)
+	}
+	if !reflect.DeepEqual(info.Scopes, []string{"wellness.read"}) {
+		t.Errorf("expected the requested scopes, got %v", info.Scopes)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.TokenInfo(ctx); err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestTokenInfoGrantedScopes(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"0123456789abcdefghijklmnopqrstuvwxyz","token_type":"Bearer","expires_in":3600,"scope":"wellness.read wellness.write"}`))
+	}))
+	defer ts.Close()
+	c, err := NewClient(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := c.TokenInfo(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.AccessTokenPrefix != "012345..." {
+		t.Errorf("expected at most 6 characters of the token, got %q", info.AccessTokenPrefix)
+	}
+	if !reflect.DeepEqual(info.Scopes, []string{"wellness.read", "wellness.write"}) {
+		t.Errorf("expected the granted scopes, got %v", info.Scopes)
+	}
+}
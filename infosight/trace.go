@@ -0,0 +1,119 @@
+package infosight
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// redacted replaces secrets in trace records
+const redacted = "xxxxx"
+
+// TraceRecord is a structured record of a request and its response, see WithTraceRecorder
+type TraceRecord struct {
+	Time           time.Time
+	Method         string
+	URL            string
+	RequestHeader  http.Header
+	RequestBody    string
+	Status         int
+	ResponseHeader http.Header
+	ResponseBody   string
+	DurationMs     int64
+	// Error of requests which did not receive a response
+	Error string
+}
+
+// WithTraceRecorder calls fn with a TraceRecord of every request once its response is received. Credentials in
+// headers and query parameters are redacted. The response body is read into memory to be recorded.
+func WithTraceRecorder(fn func(TraceRecord)) ClientOption {
+	return func(c *Client) error {
+		c.traceRecorder = fn
+		return nil
+	}
+}
+
+// recordTrace passes the record of an exchange to the trace recorder and HAR capture, r may be nil if err is set
+func (c *Client) recordTrace(start time.Time, req *http.Request, r *http.Response, err error) {
+	record := TraceRecord{
+		Time:          start,
+		Method:        req.Method,
+		URL:           redactURL(req.URL),
+		RequestHeader: redactHeader(req.Header),
+		DurationMs:    time.Since(start).Milliseconds(),
+	}
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			raw, _ := ioutil.ReadAll(body)
+			body.Close()
+			record.RequestBody = string(raw)
+		}
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	if r != nil {
+		record.Status = r.StatusCode
+		record.ResponseHeader = redactHeader(r.Header)
+		raw, err := ioutil.ReadAll(r.Body)
+		r.Body.Close()
+		r.Body = ioutil.NopCloser(bytes.NewReader(raw))
+		record.ResponseBody = string(raw)
+		if err != nil {
+			record.Error = err.Error()
+		}
+	}
+	if c.harCapture != nil {
+		c.harCapture.add(record)
+	}
+	if c.traceRecorder != nil {
+		c.traceRecorder(record)
+	}
+}
+
+// isSecret reports whether a header or query parameter name carries credentials
+func isSecret(name string) bool {
+	name = strings.ToLower(name)
+	switch name {
+	case "authorization", "proxy-authorization", "cookie", "set-cookie":
+		return true
+	}
+	for _, secret := range []string{"token", "secret", "password", "key"} {
+		if strings.Contains(name, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactHeader returns a copy of h with credentials redacted
+func redactHeader(h http.Header) http.Header {
+	redactedHeader := h.Clone()
+	for name := range redactedHeader {
+		if isSecret(name) {
+			redactedHeader[name] = []string{redacted}
+		}
+	}
+	return redactedHeader
+}
+
+// redactURL returns u with password and credential query parameters redacted
+func redactURL(u *url.URL) string {
+	query := u.Query()
+	changed := false
+	for name := range query {
+		if isSecret(name) {
+			query[name] = []string{redacted}
+			changed = true
+		}
+	}
+	if !changed {
+		return u.Redacted()
+	}
+	clean := *u
+	clean.RawQuery = query.Encode()
+	return clean.Redacted()
+}
@@ -0,0 +1,50 @@
+package infosight
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestWithTraceRecorder(t *testing.T) {
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "session=secret")
+		issuesHandler(issuesFixture)(w, r)
+	})
+	var records []TraceRecord
+	c, err := NewClient(ts.URL, WithTraceRecorder(func(record TraceRecord) {
+		records = append(records, record)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	page, err := GetPage[Issue](c.Wellness, context.Background(), "issues", WithQueryParam("api_key", "s3cret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Items) != 2 {
+		t.Fatalf("expected the recorded response to be decoded, got %d issues", len(page.Items))
+	}
+
+	if len(records) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(records))
+	}
+	record := records[0]
+	if record.Method != "GET" || record.Status != http.StatusOK || record.Error != "" {
+		t.Errorf("unexpected record %+v", record)
+	}
+	if !strings.HasPrefix(record.URL, ts.URL+"/wellness/v1/issues?") || !strings.Contains(record.URL, "api_key=xxxxx") || strings.Contains(record.URL, "s3cret") {
+		t.Errorf("expected the api key to be redacted, got %s", record.URL)
+	}
+	if record.ResponseHeader.Get("Set-Cookie") != "xxxxx" {
+		t.Errorf("expected the cookie to be redacted, got %q", record.ResponseHeader.Get("Set-Cookie"))
+	}
+	if !strings.Contains(record.ResponseBody, `"5d9eb55a28c7eb0001f472ec"`) {
+		t.Errorf("expected the response body to be recorded, got %q", record.ResponseBody)
+	}
+	if record.RequestHeader.Get("User-Agent") != "go-infosight" {
+		t.Errorf("expected the request headers to be recorded, got %v", record.RequestHeader)
+	}
+}
@@ -0,0 +1,40 @@
+package infosight
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// WaitForIssueState polls the issue id every pollInterval until its status value matches target (case insensitive),
+// e.g. to wait until an issue is resolved after applying a fix. It returns the issue in the target state, or the last
+// fetched issue together with an error wrapping ctx.Err() once ctx is done. InfoSight supports no conditional requests,
+// so every poll fetches the whole issue, choose the interval accordingly.
+func (w *Wellness) WaitForIssueState(ctx context.Context, id string, target string, pollInterval time.Duration) (*Issue, error) {
+	if pollInterval <= 0 {
+		return nil, fmt.Errorf("invalid poll interval %v", pollInterval)
+	}
+	var issue *Issue
+	for {
+		current, err := w.GetIssue(ctx, id)
+		if err != nil {
+			if ctx.Err() != nil {
+				return issue, fmt.Errorf("issue %s did not reach state %q: %w", id, target, ctx.Err())
+			}
+			return issue, err
+		}
+		issue = current
+		if issue.Status != nil && strings.EqualFold(issue.Status.Value, target) {
+			return issue, nil
+		}
+
+		timer := time.NewTimer(pollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return issue, fmt.Errorf("issue %s did not reach state %q: %w", id, target, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
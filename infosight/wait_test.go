@@ -0,0 +1,53 @@
+package infosight
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWaitForIssueState(t *testing.T) {
+	var polls int32
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/wellness/v1/issue/5d9eb55a28c7eb0001f472eb" {
+			http.NotFound(w, r)
+			return
+		}
+		state := "open"
+		if atomic.AddInt32(&polls, 1) >= 3 {
+			state = "resolved"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"request":{"id":"5d9eb55a28c7eb0001f472eb"},"data":{"uuid":"5d9eb55a28c7eb0001f472eb","status":{"value":%q}}}`, state)
+	})
+	c, err := NewClient(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issue, err := c.Wellness.WaitForIssueState(context.Background(), "5d9eb55a28c7eb0001f472eb", "Resolved", time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if issue.Status.Value != "resolved" || atomic.LoadInt32(&polls) != 3 {
+		t.Errorf("expected the resolved issue after 3 polls, got %q after %d polls", issue.Status.Value, polls)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	issue, err = c.Wellness.WaitForIssueState(ctx, "5d9eb55a28c7eb0001f472eb", "closed", time.Millisecond)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+	if issue == nil || issue.Status.Value != "resolved" {
+		t.Errorf("expected the last fetched issue, got %+v", issue)
+	}
+
+	if _, err := c.Wellness.WaitForIssueState(context.Background(), "unknown", "closed", time.Millisecond); err == nil {
+		t.Error("expected an error for an unknown issue")
+	}
+}
@@ -1,57 +1,576 @@
 package infosight
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"math/rand"
+	"mime"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 var (
-	defaultVersion string = "v1"
+	defaultVersion  string = "v1"
+	defaultDomain   Domain = DomainNimble
+	defaultMaxItems int    = 10000
 )
 
+// ErrTooManyObjects is returned if an object set has more objects than Wellness.MaxItems allows to fetch at once
+var ErrTooManyObjects = errors.New("infosight: too many objects")
+
+// Wellness wraps the wellness API. The API is read only, issues can not be acknowledged or closed through it.
 type Wellness struct {
 	*Client
 
 	Version string
+	Domain  Domain
+	// DiffKey is the field identifying objects in DiffObjectSet, defaults to _id
+	DiffKey string
+	// DefaultPaging is applied to requests which do not specify paging, zero values (and Total) are ignored
+	DefaultPaging PagingInfo
+	// MaxItems is the maximum number of objects GetAllIssues fetches, defaults to 10000
+	MaxItems int
+
+	defaultsMu sync.RWMutex
+	defaults   map[string][]RequestOption
+	// defaultFields are requested from an object set unless a call selects fields, see SetDefaultFields
+	defaultFields map[string][]string
 }
 
 func NewWellness(client *Client) *Wellness {
 	return &Wellness{
-		client,
-		defaultVersion,
+		Client:   client,
+		Version:  defaultVersion,
+		Domain:   client.domain,
+		DiffKey:  defaultDiffKey,
+		MaxItems: defaultMaxItems,
+	}
+}
+
+// SetDefaults registers options applied whenever objectSet is fetched, e.g.
+// SetDefaults("issues", WithPaging(0, 100), WithSort("condition.severity asc")).
+// Options passed to a call override the defaults, calling SetDefaults without options removes them.
+func (w *Wellness) SetDefaults(objectSet string, opts ...RequestOption) {
+	w.defaultsMu.Lock()
+	defer w.defaultsMu.Unlock()
+	if len(opts) == 0 {
+		delete(w.defaults, objectSet)
+		return
+	}
+	if w.defaults == nil {
+		w.defaults = map[string][]RequestOption{}
+	}
+	w.defaults[objectSet] = append([]RequestOption{}, opts...)
+}
+
+// SetDefaultFields registers the fields requested whenever objectSet is fetched (see WithFields), e.g. to reduce the
+// payload of list views with SetDefaultFields("issues", "_id", "condition.severity", "status.value"). Fields selected
+// by SetDefaults or a call override them, calling SetDefaultFields without fields removes them.
+func (w *Wellness) SetDefaultFields(objectSet string, fields ...string) error {
+	if len(fields) > 0 {
+		if err := WithFields(fields...)(&requestOptions{query: url.Values{}}); err != nil {
+			return err
+		}
+	}
+	w.defaultsMu.Lock()
+	defer w.defaultsMu.Unlock()
+	if len(fields) == 0 {
+		delete(w.defaultFields, objectSet)
+		return nil
+	}
+	if w.defaultFields == nil {
+		w.defaultFields = map[string][]string{}
+	}
+	w.defaultFields[objectSet] = append([]string{}, fields...)
+	return nil
+}
+
+// requestOptions applies the domain of ctx, the default paging, the default fields and defaults of an object set and opts
+func (w *Wellness) requestOptions(ctx context.Context, objectSet string, opts ...RequestOption) (*requestOptions, error) {
+	w.defaultsMu.RLock()
+	defaults := w.defaults[objectSet]
+	fields := w.defaultFields[objectSet]
+	w.defaultsMu.RUnlock()
+	if len(fields) > 0 {
+		defaults = append([]RequestOption{WithFields(fields...)}, defaults...)
+	}
+
+	domain := w.Domain
+	if d, ok, err := domainFromContext(ctx); err != nil {
+		return nil, err
+	} else if ok {
+		domain = d
+	}
+	o := &requestOptions{
+		query: url.Values{"domain": {domain.String()}},
+	}
+	if w.DefaultPaging.Skip > 0 {
+		o.query.Set("skip", strconv.Itoa(w.DefaultPaging.Skip))
+	}
+	if w.DefaultPaging.Limit > 0 {
+		o.query.Set("limit", strconv.Itoa(w.DefaultPaging.Limit))
+	}
+	for _, opt := range append(append([]RequestOption{}, defaults...), opts...) {
+		if err := opt(o); err != nil {
+			return nil, err
+		}
+	}
+	if limit := o.query.Get("limit"); w.maxPageSize > 0 && limit != "" {
+		if n, err := strconv.Atoi(limit); err == nil && (n == 0 || n > w.maxPageSize) {
+			o.clampedLimit = limit
+			o.query.Set("limit", strconv.Itoa(w.maxPageSize))
+		}
+	}
+	return o, nil
+}
+
+// objectSetURL builds the query url of an object set
+func (w *Wellness) objectSetURL(ctx context.Context, objectSet string, opts ...RequestOption) (string, error) {
+	o, err := w.requestOptions(ctx, objectSet, opts...)
+	if err != nil {
+		return "", err
+	}
+	return w.queryURL(objectSet, o), nil
+}
+
+// queryURL formats the query url of an object set with the collected options
+func (w *Wellness) queryURL(objectSet string, o *requestOptions) string {
+	return fmt.Sprintf("%swellness/%s/%s?%s", w.Server, w.Version, objectSet, o.query.Encode())
+}
+
+// ObjectSetURL returns the url GetObjectSetContext would request for objectSet with opts (including the domain,
+// the default paging and the defaults of the object set) without sending a request, e.g. for logging or other tools
+func (w *Wellness) ObjectSetURL(objectSet string, opts ...RequestOption) (string, error) {
+	return w.objectSetURL(w.ctx, objectSet, opts...)
+}
+
+// knownObjectSets are the object sets documented in the wellness API specification
+var knownObjectSets = []string{"issues"}
+
+// KnownObjectSets returns the names of the object sets of the wellness API
+func KnownObjectSets() []string {
+	return append([]string{}, knownObjectSets...)
+}
+
+// ValidateObjectSet checks that name is a well formed name of a known object set, e.g. before
+// querying an object set given by a user
+func (w *Wellness) ValidateObjectSet(name string) error {
+	if err := validateObjectSetName(name); err != nil {
+		return err
+	}
+	for _, known := range knownObjectSets {
+		if name == known {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown object set %q, known object sets are %s", name, strings.Join(knownObjectSets, ", "))
+}
+
+// validateObjectSetName checks that name can be used as path segment of the query url
+func validateObjectSetName(name string) error {
+	if name == "" {
+		return errors.New("empty object set name")
+	}
+	if strings.ContainsAny(name, " \t\r\n/\\?#%&") {
+		return fmt.Errorf("invalid object set name %q", name)
+	}
+	return nil
+}
+
+// GetObjectSetContext fetches a list of objects, the request is bound to ctx
+func (w *Wellness) GetObjectSetContext(ctx context.Context, objectSet string, opts ...RequestOption) (*APIResponse, error) {
+	var apiResponse APIResponse
+	ignored, err := w.fetchInto(ctx, objectSet, &apiResponse, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if ignored {
+		return &APIResponse{Data: []interface{}{}}, nil
+	}
+	return &apiResponse, nil
+}
+
+// GetInto fetches an object set and decodes the whole response into dst like json.Unmarshal, e.g. into an own
+// envelope type with a typed data field. If the fault is ignored (see WithIgnoreFaultCodes) dst is left untouched.
+func (w *Wellness) GetInto(ctx context.Context, objectSet string, dst interface{}, opts ...RequestOption) error {
+	_, err := w.fetchInto(ctx, objectSet, dst, opts...)
+	return err
+}
+
+// fetchInto fetches an object set and decodes the response into dst, it reports whether the fault of
+// the response was ignored instead
+func (w *Wellness) fetchInto(ctx context.Context, objectSet string, dst interface{}, opts ...RequestOption) (bool, error) {
+	o, err := w.requestOptions(ctx, objectSet, opts...)
+	if err != nil {
+		return false, err
+	}
+	if o.clampedLimit != "" {
+		w.Warnf("limit %s of %s exceeds the maximum page size, requesting %d objects", o.clampedLimit, objectSet, w.maxPageSize)
+	}
+	if o.userAgent != "" {
+		ctx = context.WithValue(ctx, userAgentContextKey, o.userAgent)
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", w.queryURL(objectSet, o), nil)
+	if err != nil {
+		return false, err
+	}
+
+	r, err := w.do(req)
+	if w.isIgnoredFault(err) {
+		w.Debugf("ignoring fault of %s: %v", req.URL.Redacted(), err)
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer r.Body.Close()
+
+	if err := checkContentType(req, r); err != nil {
+		return false, err
+	}
+	err = decodeJSON(r.Body, dst, w.unmarshal)
+	if err != nil {
+		var decodeErr *DecodeError
+		if errors.As(err, &decodeErr) {
+			decodeErr.URL = req.URL.Redacted()
+			decodeErr.StatusCode = r.StatusCode
+		}
+		return false, err
+	}
+	return false, nil
+}
+
+// maxPooledBuffer is the capacity up to which buffers are returned to the pool,
+// so a single huge response does not pin its memory
+const maxPooledBuffer = 4 << 20
+
+// bufferPool recycles the buffers response bodies are read into
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+// ContentTypeError is returned if a response is not JSON, e.g. an HTML page of a gateway
+type ContentTypeError struct {
+	URL         string
+	StatusCode  int
+	ContentType string
+}
+
+func (e *ContentTypeError) Error() string {
+	return fmt.Sprintf("unexpected content type %q of %s (status %d)", e.ContentType, e.URL, e.StatusCode)
+}
+
+// checkContentType returns a ContentTypeError unless the response is JSON. Responses without content type
+// and text/plain (which servers and content sniffing use for unlabeled JSON) are accepted as well.
+func checkContentType(req *http.Request, r *http.Response) error {
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		return nil
 	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err == nil && (mediaType == "application/json" || mediaType == "text/plain" || strings.HasSuffix(mediaType, "+json")) {
+		return nil
+	}
+	return &ContentTypeError{URL: req.URL.Redacted(), StatusCode: r.StatusCode, ContentType: contentType}
+}
+
+// maxSnippet is the length of the body snippet of a DecodeError
+const maxSnippet = 200
+
+// DecodeError is returned if a response body is no valid JSON of the expected shape
+type DecodeError struct {
+	URL        string
+	StatusCode int
+	// Snippet of the body around the offending position
+	Snippet string
+	Err     error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("decoding response of %s (status %d): %v, body: %q", e.URL, e.StatusCode, e.Err, e.Snippet)
+}
+
+// Unwrap returns the JSON error
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// decodeJSON reads body into a pooled buffer and decodes it into v with unmarshal (json.Unmarshal if nil),
+// JSON errors are returned as DecodeError. The unmarshaler must not retain the data, so the buffer can be reused.
+func decodeJSON(body io.Reader, v interface{}, unmarshal func([]byte, interface{}) error) error {
+	if unmarshal == nil {
+		unmarshal = json.Unmarshal
+	}
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer func() {
+		if buf.Cap() <= maxPooledBuffer {
+			bufferPool.Put(buf)
+		}
+	}()
+
+	if _, err := buf.ReadFrom(body); err != nil {
+		return err
+	}
+	if err := unmarshal(buf.Bytes(), v); err != nil {
+		return &DecodeError{Snippet: snippet(buf.Bytes(), err), Err: err}
+	}
+	return nil
+}
+
+// snippet returns the part of raw around the position of a JSON error
+func snippet(raw []byte, err error) string {
+	offset := int64(0)
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &syntaxErr) {
+		offset = syntaxErr.Offset
+	} else if errors.As(err, &typeErr) {
+		offset = typeErr.Offset
+	}
+	start := int(offset) - maxSnippet/2
+	if start < 0 {
+		start = 0
+	}
+	end := start + maxSnippet
+	if end > len(raw) {
+		end = len(raw)
+	}
+	if start > end {
+		start = end
+	}
+	return string(raw[start:end])
+}
+
+// GetObjectSetAsOf fetches the objects of an object set up to the timestamp at (end_time), e.g. to compare the
+// issues raised until a point in time. InfoSight keeps no snapshots, so the objects are returned in their current
+// state, see WithTimeRange.
+func (w *Wellness) GetObjectSetAsOf(ctx context.Context, objectSet string, at time.Time, opts ...RequestOption) (*APIResponse, error) {
+	if at.IsZero() {
+		return nil, errors.New("missing timestamp")
+	}
+	opts = append(append([]RequestOption{}, opts...), WithTimeRange(time.Time{}, at))
+	return w.GetObjectSetContext(ctx, objectSet, opts...)
 }
 
 // GetObjectSet fetches a list of objects
 // url.Values
 func (w *Wellness) GetObjectSet(objectSet string) (interface{}, error) {
-	queryURL := fmt.Sprintf("%swellness/%s/%s?domain=urn:nimble", w.Server, w.Version, objectSet)
-	req, err := http.NewRequest("GET", queryURL, nil)
+	apiResponse, err := w.GetObjectSetContext(w.ctx, objectSet)
 	if err != nil {
 		return nil, err
 	}
+	return *apiResponse, nil
+}
 
-	r, err := w.do(req)
+func (w *Wellness) GetIssues() (interface{}, error) {
+	return w.GetObjectSet("issues")
+}
 
+// GetIssue fetches a single issue by its uuid
+func (w *Wellness) GetIssue(ctx context.Context, id string) (*Issue, error) {
+	if id == "" {
+		return nil, errors.New("empty issue id")
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%swellness/%s/issue/%s", w.Server, w.Version, url.PathEscape(id)), nil)
 	if err != nil {
 		return nil, err
 	}
+	r, err := w.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+
+	if err := checkContentType(req, r); err != nil {
+		return nil, err
+	}
+	var response struct {
+		Data *Issue `json:"data"`
+	}
+	if err := decodeJSON(r.Body, &response, w.unmarshal); err != nil {
+		var decodeErr *DecodeError
+		if errors.As(err, &decodeErr) {
+			decodeErr.URL = req.URL.Redacted()
+			decodeErr.StatusCode = r.StatusCode
+		}
+		return nil, err
+	}
+	if response.Data == nil {
+		return nil, fmt.Errorf("no issue %s in response", id)
+	}
+	return response.Data, nil
+}
 
-	if r.StatusCode > 399 {
-		return NewFaultResponse(r)
+// pollIssues fetches the first page of a poll with first and the following pages with rest,
+// it returns the issues and the session id reported by the first page
+func (w *Wellness) pollIssues(ctx context.Context, first []RequestOption, rest []RequestOption) ([]Issue, string, error) {
+	it := w.IterateObjectSet(ctx, "issues", 0, first...)
+	if !it.Next() {
+		return []Issue{}, "", it.Err()
+	}
+	sessionID := ""
+	if status := it.Page().Status; status != nil && status.SessionInfo != nil {
+		sessionID = status.SessionInfo.SessionID
+	}
+	issues, err := decodeData[Issue](it.Page(), w.unmarshal)
+	if err != nil {
+		return nil, "", err
+	}
+	if it.done {
+		return issues, sessionID, nil
 	}
 
-	var apiResponse APIResponse
-	decoder := json.NewDecoder(r.Body)
-	err = decoder.Decode(&apiResponse)
+	it = w.ResumeObjectSet(ctx, "issues", it.limit, it.skip, rest...)
+	for it.Next() {
+		page, err := decodeData[Issue](it.Page(), w.unmarshal)
+		if err != nil {
+			return nil, "", err
+		}
+		issues = append(issues, page...)
+	}
+	return issues, sessionID, it.Err()
+}
+
+// GetAllIssues fetches all issues matching opts page by page. If there are more than MaxItems issues,
+// the issues fetched so far are returned together with ErrTooManyObjects.
+func (w *Wellness) GetAllIssues(ctx context.Context, opts ...RequestOption) ([]Issue, error) {
+	issues := []Issue{}
+	it := w.IterateObjectSet(ctx, "issues", 0, opts...)
+	for it.Next() {
+		page, err := decodeData[Issue](it.Page(), w.unmarshal)
+		if err != nil {
+			return issues, err
+		}
+		if w.MaxItems > 0 && len(issues)+len(page) > w.MaxItems {
+			return append(issues, page[:w.MaxItems-len(issues)]...), fmt.Errorf("%w: more than %d issues", ErrTooManyObjects, w.MaxItems)
+		}
+		issues = append(issues, page...)
+	}
+	return issues, it.Err()
+}
+
+// GetRecentIssues fetches the issues modified within the last window (see WithClock), e.g. for an alerting loop.
+// The server is asked for issues since the cutoff (start_time), issues whose status timestamp and latest occurrence
+// are both older are dropped as well. Issues without any timestamp are kept.
+func (w *Wellness) GetRecentIssues(ctx context.Context, window time.Duration, opts ...RequestOption) ([]Issue, error) {
+	if window <= 0 {
+		return nil, fmt.Errorf("invalid window %v", window)
+	}
+	cutoff := w.now().Add(-window)
+	opts = append(append([]RequestOption{}, opts...), WithTimeRange(cutoff, time.Time{}))
+	issues, err := w.GetAllIssues(ctx, opts...)
+	recent := issues[:0]
+	for _, issue := range issues {
+		if issue.Status == nil || (issue.Status.Timestamp.IsZero() && issue.Status.LatestOccurence.IsZero()) ||
+			!issue.Status.Timestamp.Before(cutoff) || !issue.Status.LatestOccurence.Before(cutoff) {
+			recent = append(recent, issue)
+		}
+	}
+	return recent, err
+}
+
+// GetIssueIDs fetches the ids (_id) of all issues matching opts, it only requests the id field
+// and is much cheaper than fetching the issues, e.g. to reconcile local state
+func (w *Wellness) GetIssueIDs(ctx context.Context, opts ...RequestOption) ([]string, error) {
+	ids := []string{}
+	opts = append(append([]RequestOption{}, opts...), WithFields("_id"))
+	it := w.IterateObjectSet(ctx, "issues", 0, opts...)
+	for it.Next() {
+		for _, item := range it.Page().Data {
+			if obj, ok := item.(map[string]interface{}); ok {
+				if id, ok := obj["_id"].(string); ok {
+					ids = append(ids, id)
+				}
+			}
+		}
+	}
+	return ids, it.Err()
+}
+
+// CountObjectSet returns the number of objects matching opts without downloading them. It requests a single id
+// and reads the total number of objects from the paging information of the response.
+func (w *Wellness) CountObjectSet(ctx context.Context, objectSet string, opts ...RequestOption) (int, error) {
+	opts = append(append([]RequestOption{}, opts...), WithPaging(0, 1), WithFields("_id"))
+	r, err := w.GetObjectSetContext(ctx, objectSet, opts...)
 	if err != nil {
-		return nil, err
+		return 0, err
+	}
+	if r.Request != nil && r.Request.Paging != nil && r.Request.Paging.Total > 0 {
+		return r.Request.Paging.Total, nil
+	}
+	if len(r.Data) > 0 {
+		return 0, fmt.Errorf("no total number of objects reported for %s", objectSet)
 	}
+	return 0, nil
+}
 
-	return apiResponse, err
+// flusher is implemented by buffered writers like bufio.Writer
+type flusher interface {
+	Flush() error
 }
 
-func (w *Wellness) GetIssues() (interface{}, error) {
-	return w.GetObjectSet("issues")
+// ExportObjectSetNDJSON writes all objects of an object set to wr as newline delimited JSON, one object per line.
+// If wr is buffered it is flushed after every page. Use WithProgress to report the progress of the export.
+func (w *Wellness) ExportObjectSetNDJSON(ctx context.Context, objectSet string, wr io.Writer, opts ...RequestOption) error {
+	encoder := json.NewEncoder(wr)
+	it := w.IterateObjectSet(ctx, objectSet, defaultPageSize, opts...)
+	for it.Next() {
+		for _, item := range it.Page().Data {
+			if err := encoder.Encode(item); err != nil {
+				return err
+			}
+		}
+		if f, ok := wr.(flusher); ok {
+			if err := f.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return it.Err()
+}
+
+// WatchIssues polls the issues every interval (with 10% jitter) and calls fn with the result until
+// ctx is done or fn returns an error. The first poll creates a session with the filters in opts,
+// subsequent polls only fetch issues which are new since the previous poll. The other options (e.g. WithFields)
+// are kept for all polls, every poll fetches all pages.
+func (w *Wellness) WatchIssues(ctx context.Context, interval time.Duration, fn func([]Issue) error, opts ...RequestOption) error {
+	if interval <= 0 {
+		return fmt.Errorf("invalid poll interval %v", interval)
+	}
+	first := append(append([]RequestOption{}, opts...), WithSession())
+	rest := opts
+	for {
+		issues, sessionID, err := w.pollIssues(ctx, first, rest)
+		if err != nil {
+			return err
+		}
+		if sessionID != "" {
+			// the session applies the filters
+			first = append(append([]RequestOption{}, opts...), withoutFilters(), WithSessionID(sessionID))
+			rest = first
+		}
+		if err := fn(issues); err != nil {
+			return err
+		}
+
+		jitter := time.Duration(rand.Int63n(int64(interval)/5+1)) - interval/10
+		timer := time.NewTimer(interval + jitter)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
 }
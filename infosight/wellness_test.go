@@ -1,11 +1,27 @@
 package infosight
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestStatus(t *testing.T) {
+	if os.Getenv("INFOSIGHT_CLIENT_KEY") == "" {
+		t.Skip("INFOSIGHT_CLIENT_KEY not set")
+	}
 	c, err := NewClientFromEnvironment(WithTrace(true))
 	if err != nil {
 		t.Error(err)
@@ -20,3 +36,1427 @@ func TestStatus(t *testing.T) {
 
 	fmt.Printf("%v", i)
 }
+
+// issuesFixture holds two issues as documented in the wellness API specification
+const issuesFixture = `[
+	{
+		"_id": "5d9eb55a28c7eb0001f472eb",
+		"asset": {"urn": "urn:nimble:array:AF-12345", "name": "MJ-SAN1"},
+		"object": {"urn": "urn:nimble:array:AF-12345"},
+		"condition": {"urn": "urn:nimble:pachinko:svcfretainedss", "name": "VM host snapshot cleanup required", "category": "Data_Protection", "severity": "critical"},
+		"status": {"value": "open", "timestamp": "2018-08-20T19:08:55.000Z", "initialoccurence": "2018-08-20T19:08:55.000Z", "latestoccurence": "2018-08-20T19:08:55.000Z", "occurrences": 1, "expiresat": "2018-08-21T19:08:55.000Z"}
+	},
+	{
+		"_id": "5d9eb55a28c7eb0001f472ec",
+		"domain": "nimble",
+		"asset": {"urn": "urn:nimble:array:AF-67890", "name": "AF-67890"},
+		"object": {"urn": "urn:nimble:array:AF-67890", "name": "Evolution-Nimble"},
+		"condition": {"urn": "urn:nimble:ssdfailed", "name": "Array Evolution-Nimble has reported that the drive in slot 1.A failed.", "severity": "non-critical", "category": "Hardware"},
+		"title": "Array Evolution-Nimble has reported that the drive in slot 1.A failed.",
+		"body": {"type": "embedded", "mediatype": "text/plain", "content": "Array Evolution-Nimble has reported that the drive in slot 1.A failed."},
+		"status": {"value": "new", "timestamp": "2020-05-29T02:58:53.643Z", "occurrences": 1},
+		"escalation": [{"trigger": "support case", "caseid": "5003000000D8cuI", "href": "https://support.hpe.com/case/5003000000D8cuI", "casestatus": "new"}],
+		"nimbledata": {"automationcode": "ssdfailed", "severity": "non-critical", "value": "new"}
+	}
+]`
+
+// pagedHandler serves an object set of total objects honoring skip and limit
+func pagedHandler(total int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		skip, _ := strconv.Atoi(r.URL.Query().Get("skip"))
+		limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+		if err != nil {
+			limit = defaultPageSize
+		}
+		data := []map[string]interface{}{}
+		for i := skip; i < total && i < skip+limit; i++ {
+			data = append(data, map[string]interface{}{"_id": fmt.Sprintf("%024x", i)})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"request": map[string]interface{}{"paging": map[string]int{"skip": skip, "limit": limit, "total": total}},
+			"data":    data,
+			"status":  map[string]string{"message": "success"},
+		})
+	}
+}
+
+func TestCountObjectSet(t *testing.T) {
+	var query url.Values
+	paged := pagedHandler(1245)
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		if query.Get("domain") == "urn:untotaled" {
+			issuesHandler(issuesFixture)(w, r)
+			return
+		}
+		paged(w, r)
+	})
+	c, err := NewClient(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count, err := c.Wellness.CountObjectSet(context.Background(), "issues", WithFilter("condition.severity", "critical"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1245 {
+		t.Errorf("expected 1245 objects, got %d", count)
+	}
+	if query.Get("limit") != "1" || query.Get("fields") != "_id" || query.Get("condition.severity") != "critical" {
+		t.Errorf("expected a single id to be requested, got %v", query)
+	}
+
+	if _, err := c.Wellness.CountObjectSet(ContextWithDomain(context.Background(), "urn:untotaled"), "issues"); err == nil {
+		t.Error("expected an error if no total is reported")
+	}
+}
+
+func TestExportObjectSetNDJSON(t *testing.T) {
+	ts := newTestServer(t, pagedHandler(450))
+	c, err := NewClient(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	wr := bufio.NewWriter(&buf)
+	if err := c.Wellness.ExportObjectSetNDJSON(context.Background(), "issues", wr); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	if len(lines) != 450 {
+		t.Fatalf("expected 450 lines, got %d", len(lines))
+	}
+	var item map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[449]), &item); err != nil {
+		t.Fatal(err)
+	}
+	if item["_id"] != fmt.Sprintf("%024x", 449) {
+		t.Errorf("unexpected last object %v", item)
+	}
+}
+
+func TestExportObjectSetNDJSONProgress(t *testing.T) {
+	ts := newTestServer(t, pagedHandler(450))
+	c, err := NewClient(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var progress [][2]int
+	err = c.Wellness.ExportObjectSetNDJSON(context.Background(), "issues", ioutil.Discard, WithProgress(func(fetched int, total int) {
+		progress = append(progress, [2]int{fetched, total})
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][2]int{{200, 450}, {400, 450}, {450, 450}}
+	if !reflect.DeepEqual(progress, expected) {
+		t.Errorf("expected progress %v, got %v", expected, progress)
+	}
+}
+
+func TestExportObjectSetNDJSONCanceled(t *testing.T) {
+	ts := newTestServer(t, pagedHandler(450))
+	c, err := NewClient(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var buf bytes.Buffer
+	if err := c.Wellness.ExportObjectSetNDJSON(ctx, "issues", &buf); err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("expected no output, got %d bytes", buf.Len())
+	}
+}
+
+func TestGetAllIssues(t *testing.T) {
+	ts := newTestServer(t, pagedHandler(450))
+	c, err := NewClient(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issues, err := c.Wellness.GetAllIssues(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) != 450 || issues[449].ID != fmt.Sprintf("%024x", 449) {
+		t.Fatalf("expected all 450 issues, got %d", len(issues))
+	}
+
+	c.Wellness.MaxItems = 300
+	issues, err = c.Wellness.GetAllIssues(context.Background())
+	if !errors.Is(err, ErrTooManyObjects) || len(issues) != 300 {
+		t.Errorf("expected 300 issues and ErrTooManyObjects, got %d issues and %v", len(issues), err)
+	}
+}
+
+func TestGetIssueIDs(t *testing.T) {
+	var fields []string
+	paged := pagedHandler(250)
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		fields = append(fields, r.URL.Query().Get("fields"))
+		paged(w, r)
+	})
+	c, err := NewClient(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids, err := c.Wellness.GetIssueIDs(context.Background(), WithFields("uuid"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 250 || ids[0] != fmt.Sprintf("%024x", 0) || ids[249] != fmt.Sprintf("%024x", 249) {
+		t.Errorf("expected 250 ids, got %d", len(ids))
+	}
+	if !reflect.DeepEqual(fields, []string{"_id", "_id"}) {
+		t.Errorf("expected only the id to be requested, got %v", fields)
+	}
+}
+
+func TestGetPage(t *testing.T) {
+	var query url.Values
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"request":{"paging":{"skip":2,"limit":2,"total":7}},"data":%s,"status":{"message":"success"}}`, issuesFixture)
+	})
+	c, err := NewClient(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	page, err := GetPage[Issue](c.Wellness, context.Background(), "issues", WithPaging(2, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query.Get("skip") != "2" || query.Get("limit") != "2" {
+		t.Errorf("unexpected query %v", query)
+	}
+	if page.Skip != 2 || page.Limit != 2 || page.Total != 7 {
+		t.Errorf("unexpected paging skip=%d limit=%d total=%d", page.Skip, page.Limit, page.Total)
+	}
+	if len(page.Items) != 2 {
+		t.Fatalf("expected 2 issues, got %d", len(page.Items))
+	}
+	issue := page.Items[1]
+	if issue.ID != "5d9eb55a28c7eb0001f472ec" || issue.Condition.Severity != "non-critical" || issue.Escalation[0].CaseID != "5003000000D8cuI" {
+		t.Errorf("unexpected issue %+v", issue)
+	}
+}
+
+func TestGetPageWithoutPagingEcho(t *testing.T) {
+	ts := newTestServer(t, issuesHandler(issuesFixture))
+	c, err := NewClient(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	page, err := GetPage[Issue](c.Wellness, context.Background(), "issues", WithPaging(10, 5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Skip != 10 || page.Limit != 5 || page.Total != 0 {
+		t.Errorf("unexpected paging skip=%d limit=%d total=%d", page.Skip, page.Limit, page.Total)
+	}
+	if len(page.Items) != 2 {
+		t.Errorf("expected 2 issues, got %d", len(page.Items))
+	}
+}
+
+func TestWatchIssues(t *testing.T) {
+	var queries []url.Values
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.Query())
+		w.Header().Set("Content-Type", "application/json")
+		if len(queries) == 1 {
+			fmt.Fprintf(w, `{"data":%s,"status":{"message":"Success","sessionInfo":{"session_id":"5f46f2fe-fb30-4f7f-82ce-ce50e941df70","sessionStatus":"active"}}}`, issuesFixture)
+			return
+		}
+		fmt.Fprint(w, `{"data":[],"status":{"message":"Success"}}`)
+	})
+	c, err := NewClient(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errStop := errors.New("stop")
+	var polls [][]Issue
+	err = c.Wellness.WatchIssues(context.Background(), 10*time.Millisecond, func(issues []Issue) error {
+		polls = append(polls, issues)
+		if len(polls) == 2 {
+			return errStop
+		}
+		return nil
+	}, WithFilter("condition.severity", "critical"))
+	if err != errStop {
+		t.Fatalf("expected the callback error, got %v", err)
+	}
+
+	if len(polls) != 2 || len(polls[0]) != 2 || len(polls[1]) != 0 {
+		t.Errorf("unexpected polls %v", polls)
+	}
+	if queries[0].Get("session_enabled") != "true" || queries[0].Get("condition.severity") != "critical" {
+		t.Errorf("unexpected first query %v", queries[0])
+	}
+	if queries[1].Get("session_id") != "5f46f2fe-fb30-4f7f-82ce-ce50e941df70" || queries[1].Get("condition.severity") != "" {
+		t.Errorf("unexpected second query %v", queries[1])
+	}
+}
+
+func TestWatchIssuesKeepsOptions(t *testing.T) {
+	var queries []url.Values
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		queries = append(queries, q)
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case q.Get("session_enabled") == "true":
+			fmt.Fprint(w, `{"data":[{"_id":"1"}],"status":{"message":"Success","sessionInfo":{"session_id":"5f46f2fe"}}}`)
+		case q.Get("skip") == "0":
+			fmt.Fprint(w, `{"data":[{"_id":"2"},{"_id":"3"}]}`)
+		default:
+			fmt.Fprint(w, `{"data":[{"_id":"4"}]}`)
+		}
+	})
+	c, err := NewClient(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errStop := errors.New("stop")
+	var polls [][]Issue
+	err = c.Wellness.WatchIssues(context.Background(), 10*time.Millisecond, func(issues []Issue) error {
+		polls = append(polls, issues)
+		if len(polls) == 2 {
+			return errStop
+		}
+		return nil
+	}, WithFilter("condition.severity", "critical"), WithFields("_id", "condition.severity"), WithPaging(0, 2))
+	if err != errStop {
+		t.Fatalf("expected the callback error, got %v", err)
+	}
+
+	// the second poll pages through the new issues of the session
+	if len(queries) != 3 || len(polls[0]) != 1 || len(polls[1]) != 3 || polls[1][2].ID != "4" {
+		t.Fatalf("unexpected polls %v (%d requests)", polls, len(queries))
+	}
+	for _, q := range queries[1:] {
+		if q.Get("session_id") != "5f46f2fe" || q.Get("fields") != "_id,condition.severity" || q.Get("limit") != "2" {
+			t.Errorf("expected the session poll to keep the fields and paging, got %v", q)
+		}
+		if q.Get("condition.severity") != "" || q.Get("session_enabled") != "" {
+			t.Errorf("expected the session to apply the filters, got %v", q)
+		}
+	}
+	if queries[2].Get("skip") != "2" {
+		t.Errorf("expected the second page at skip 2, got %v", queries[2])
+	}
+}
+
+func TestWatchIssuesCanceled(t *testing.T) {
+	ts := newTestServer(t, issuesHandler(`[]`))
+	c, err := NewClient(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err = c.Wellness.WatchIssues(ctx, time.Hour, func(issues []Issue) error {
+		cancel()
+		return nil
+	})
+	if err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestWithQueryParam(t *testing.T) {
+	var rawQuery string
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		rawQuery = r.URL.RawQuery
+		issuesHandler(`[]`)(w, r)
+	})
+	c, err := NewClient(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = c.Wellness.GetObjectSetContext(context.Background(), "issues",
+		WithQueryParam("feature", "a"),
+		WithQueryParam("feature", "b&c"),
+		WithQueryParam("experimental filter", "x=y z"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "domain=urn%3Animble&experimental+filter=x%3Dy+z&feature=a&feature=b%26c"
+	if rawQuery != expected {
+		t.Errorf("expected query %q, got %q", expected, rawQuery)
+	}
+
+	if _, err := c.Wellness.GetObjectSetContext(context.Background(), "issues", WithQueryParam("", "a")); err == nil {
+		t.Error("expected an error for an empty parameter name")
+	}
+}
+
+func TestObjectSetURL(t *testing.T) {
+	c, err := NewClient("https://infosight.example.com/apis", WithDomain("urn:nimble"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Wellness.SetDefaults("issues", WithSort("status.timestamp desc"))
+
+	base := "https://infosight.example.com/apis/wellness/v1/issues?"
+	tests := []struct {
+		name     string
+		opts     []RequestOption
+		expected string
+	}{
+		{"defaults", nil, "domain=urn%3Animble&sort=status.timestamp+desc"},
+		{"paging", []RequestOption{WithPaging(200, 100)}, "domain=urn%3Animble&limit=100&skip=200&sort=status.timestamp+desc"},
+		{"filter", []RequestOption{WithFilter("condition.severity", "critical")}, "condition.severity=critical&domain=urn%3Animble&sort=status.timestamp+desc"},
+		{"sort", []RequestOption{WithSort("condition.severity asc")}, "domain=urn%3Animble&sort=condition.severity+asc"},
+		{"fields", []RequestOption{WithFields("_id", "title"), WithPaging(0, 1)}, "domain=urn%3Animble&fields=_id%2Ctitle&limit=1&skip=0&sort=status.timestamp+desc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := c.Wellness.ObjectSetURL("issues", tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if u != base+tt.expected {
+				t.Errorf("expected %s, got %s", base+tt.expected, u)
+			}
+		})
+	}
+
+	if _, err := c.Wellness.ObjectSetURL("issues", WithPaging(-1, 10)); err == nil {
+		t.Error("expected an error for invalid options")
+	}
+}
+
+func TestGetRecentIssues(t *testing.T) {
+	var startTime string
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		startTime = r.URL.Query().Get("start_time")
+		issuesHandler(`[
+			{"_id":"recent","status":{"timestamp":"2020-05-30T02:50:00.000Z","latestoccurence":"2020-05-30T02:50:00.000Z"}},
+			{"_id":"reoccurred","status":{"timestamp":"2020-05-29T01:00:00.000Z","latestoccurence":"2020-05-30T02:55:00.000Z"}},
+			{"_id":"old","status":{"timestamp":"2020-05-30T02:40:00.000Z","latestoccurence":"2020-05-30T02:40:00.000Z"}},
+			{"_id":"unknown"}
+		]`)(w, r)
+	})
+	now := time.Date(2020, 5, 30, 3, 0, 0, 0, time.UTC)
+	c, err := NewClient(ts.URL, WithClock(func() time.Time { return now }))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issues, err := c.Wellness.GetRecentIssues(context.Background(), 15*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if startTime != "2020-05-30T02:45:00.000Z" {
+		t.Errorf("expected the cutoff of the clock as start time, got %q", startTime)
+	}
+	ids := []string{}
+	for _, issue := range issues {
+		ids = append(ids, issue.ID)
+	}
+	if !reflect.DeepEqual(ids, []string{"recent", "reoccurred", "unknown"}) {
+		t.Errorf("unexpected recent issues %v", ids)
+	}
+
+	if _, err := c.Wellness.GetRecentIssues(context.Background(), 0); err == nil {
+		t.Error("expected an error for an empty window")
+	}
+}
+
+func TestGetInto(t *testing.T) {
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("domain") == "urn:broken" {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"data":[{"uuid":42}]}`)
+			return
+		}
+		issuesHandler(issuesFixture)(w, r)
+	})
+	c, err := NewClient(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var envelope struct {
+		Status struct {
+			Message string `json:"message"`
+		} `json:"status"`
+		Data []struct {
+			ID        string `json:"_id"`
+			Condition struct {
+				Severity string `json:"severity"`
+			} `json:"condition"`
+		} `json:"data"`
+	}
+	if err := c.Wellness.GetInto(context.Background(), "issues", &envelope); err != nil {
+		t.Fatal(err)
+	}
+	if len(envelope.Data) != 2 || envelope.Data[1].ID != "5d9eb55a28c7eb0001f472ec" || envelope.Data[1].Condition.Severity != "non-critical" {
+		t.Errorf("unexpected envelope %+v", envelope)
+	}
+
+	var issues struct {
+		Data []Issue `json:"data"`
+	}
+	err = c.Wellness.GetInto(ContextWithDomain(context.Background(), "urn:broken"), "issues", &issues)
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) || !strings.Contains(decodeErr.URL, "/wellness/v1/issues") || decodeErr.StatusCode != http.StatusOK {
+		t.Errorf("expected a DecodeError with the url and status, got %v", err)
+	}
+}
+
+func TestGetObjectSetAsOf(t *testing.T) {
+	var query url.Values
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		issuesHandler(issuesFixture)(w, r)
+	})
+	c, err := NewClient(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	at := time.Date(2020, 5, 30, 4, 58, 53, 643000000, time.FixedZone("CEST", 2*60*60))
+	r, err := c.Wellness.GetObjectSetAsOf(context.Background(), "issues", at, WithFilter("condition.severity", "critical"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Data) != 2 {
+		t.Errorf("expected 2 issues, got %d", len(r.Data))
+	}
+	if query.Get("end_time") != "2020-05-30T02:58:53.643Z" || query.Has("start_time") || query.Get("condition.severity") != "critical" {
+		t.Errorf("unexpected query %v", query)
+	}
+
+	if _, err := c.Wellness.GetObjectSetAsOf(context.Background(), "issues", time.Time{}); err == nil {
+		t.Error("expected an error for a zero timestamp")
+	}
+	if _, err := c.Wellness.GetObjectSetContext(context.Background(), "issues", WithTimeRange(at, at.Add(-time.Hour))); err == nil {
+		t.Error("expected an error for an inverted time range")
+	}
+}
+
+func TestDomainFromEnvironment(t *testing.T) {
+	var domain string
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		domain = r.URL.Query().Get("domain")
+		issuesHandler(`[]`)(w, r)
+	})
+	t.Setenv("INFOSIGHT_URL", ts.URL)
+
+	tests := []struct {
+		name     string
+		env      string
+		opts     []ClientOption
+		expected string
+	}{
+		{"default", "", nil, "urn:nimble"},
+		{"environment", "urn:3par", nil, "urn:3par"},
+		{"environment name", "3PAR", nil, "urn:3par"},
+		{"explicit option", "urn:3par", []ClientOption{WithDomain(DomainPrimera)}, "urn:primera"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("INFOSIGHT_DOMAIN", tt.env)
+			c, err := NewClientFromEnvironment(tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := c.Wellness.GetIssues(); err != nil {
+				t.Fatal(err)
+			}
+			if domain != tt.expected {
+				t.Errorf("expected domain %q, got %q", tt.expected, domain)
+			}
+		})
+	}
+}
+
+func TestContextWithDomain(t *testing.T) {
+	var domains []string
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		domains = append(domains, r.URL.Query()["domain"]...)
+		issuesHandler(`[]`)(w, r)
+	})
+	c, err := NewClient(ts.URL, WithLogin("key", "secret"), WithDomain("urn:nimble"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := ContextWithDomain(context.Background(), "urn:3par")
+	if _, err := c.Wellness.GetObjectSetContext(ctx, "issues"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Wellness.GetObjectSetContext(context.Background(), "issues"); err != nil {
+		t.Fatal(err)
+	}
+	if len(domains) != 2 || domains[0] != "urn:3par" || domains[1] != "urn:nimble" {
+		t.Errorf("expected domains [urn:3par urn:nimble], got %v", domains)
+	}
+}
+
+func TestContextWithHeaders(t *testing.T) {
+	var header http.Header
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		issuesHandler(`[]`)(w, r)
+	})
+	c, err := NewClient(ts.URL, WithHeader("X-Tenant", "default"), WithHeader("X-Client", "go-infosight"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := ContextWithHeaders(context.Background(), map[string]string{"X-Tenant": "acme", "X-Request-Id": "1"})
+	ctx = ContextWithHeaders(ctx, map[string]string{"X-Request-Id": "2"})
+	if _, err := c.Wellness.GetObjectSetContext(ctx, "issues"); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"X-Tenant": "acme", "X-Client": "go-infosight", "X-Request-Id": "2"}
+	for name, value := range expected {
+		if header.Get(name) != value {
+			t.Errorf("expected header %s=%q, got %q", name, value, header.Get(name))
+		}
+	}
+}
+
+func TestValidateObjectSet(t *testing.T) {
+	c, err := NewClient("https://infosight.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"issues", ""},
+		{"", "empty object set name"},
+		{"issues/1", `invalid object set name "issues/1"`},
+		{"open issues", `invalid object set name "open issues"`},
+		{"issues?limit=1", `invalid object set name "issues?limit=1"`},
+		{"alerts", `unknown object set "alerts", known object sets are issues`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := c.Wellness.ValidateObjectSet(tt.name)
+			if tt.expected == "" {
+				if err != nil {
+					t.Errorf("expected %q to be valid, got %v", tt.name, err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.expected {
+				t.Errorf("expected error %q, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestValidateRequest(t *testing.T) {
+	tests := []struct {
+		name      string
+		objectSet string
+		opts      []RequestOption
+		expected  string
+	}{
+		{"valid", "issues", []RequestOption{WithPaging(0, 10), WithSort("condition.severity asc", "status.timestamp"), WithFilter("condition.severity", "critical")}, ""},
+		{"session polling", "issues", []RequestOption{WithSessionID("5f46f2fe"), WithTimeRange(time.Unix(0, 0), time.Time{})}, ""},
+		{"invalid object set", "issues/1", nil, `invalid object set name "issues/1"`},
+		{"empty filter key", "issues", []RequestOption{WithFilter("", "critical")}, "empty filter field"},
+		{"empty filter info key", "issues", []RequestOption{WithFilterInfo(&FilterInfo{Query: map[string]string{"": "critical"}})}, "empty filter field"},
+		{"negative paging", "issues", []RequestOption{WithPaging(-1, 10)}, "invalid paging skip=-1 limit=10"},
+		{"conflicting paging", "issues", []RequestOption{WithPaging(0, 10), WithQueryParam("skip", "20")}, `conflicting values ["0" "20"] for skip`},
+		{"invalid sort direction", "issues", []RequestOption{WithSort("condition.severity up")}, `invalid sort order "condition.severity up", expected a field name followed by asc or desc`},
+		{"empty sort order", "issues", []RequestOption{WithSort("title asc", "")}, `invalid sort order "", expected a field name followed by asc or desc`},
+		{"session created and polled", "issues", []RequestOption{WithSession(), WithSessionID("5f46f2fe")}, "a session can not be created (WithSession) while polling one (WithSessionID)"},
+		{"session with end time", "issues", []RequestOption{WithSessionID("5f46f2fe"), WithTimeRange(time.Time{}, time.Unix(0, 0))}, "the end of the time range is ignored when polling a session"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateRequest(tt.objectSet, tt.opts...)
+			if tt.expected == "" {
+				if err != nil {
+					t.Errorf("expected the request to be valid, got %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.expected {
+				t.Errorf("expected error %q, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data":[{"_id":"1"},}`)
+	})
+	c, err := NewClient(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = c.Wellness.GetObjectSetContext(context.Background(), "issues")
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) {
+		t.Fatalf("expected a DecodeError, got %v", err)
+	}
+	var syntaxErr *json.SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		t.Errorf("expected the DecodeError to wrap the syntax error, got %v", decodeErr.Err)
+	}
+	if !strings.HasPrefix(decodeErr.URL, ts.URL+"/wellness/v1/issues?") || decodeErr.StatusCode != http.StatusOK {
+		t.Errorf("unexpected request context %s (status %d)", decodeErr.URL, decodeErr.StatusCode)
+	}
+	if decodeErr.Snippet != `{"data":[{"_id":"1"},}` || !strings.Contains(err.Error(), decodeErr.URL) {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestWithJSONUnmarshaler(t *testing.T) {
+	ts := newTestServer(t, issuesHandler(`[{"_id":"1","status":{"occurrences":3}}]`))
+	calls := 0
+	c, err := NewClient(ts.URL, WithJSONUnmarshaler(func(data []byte, v interface{}) error {
+		calls++
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		return decoder.Decode(v)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := c.Wellness.GetObjectSetContext(context.Background(), "issues")
+	if err != nil {
+		t.Fatal(err)
+	}
+	occurrences := r.Data[0].(map[string]interface{})["status"].(map[string]interface{})["occurrences"]
+	if calls != 1 || occurrences != json.Number("3") {
+		t.Errorf("expected the custom unmarshaler to be used, got %d calls and %T", calls, occurrences)
+	}
+
+	// typed decoding uses the custom unmarshaler for the objects as well
+	typed := []struct {
+		name  string
+		get   func() ([]Issue, error)
+		calls int
+	}{
+		{"GetPage", func() ([]Issue, error) {
+			page, err := GetPage[Issue](c.Wellness, context.Background(), "issues")
+			return page.Items, err
+		}, 1},
+		{"GetAllIssues", func() ([]Issue, error) {
+			return c.Wellness.GetAllIssues(context.Background())
+		}, 2},
+		{"DomainWellness.GetIssues", func() ([]Issue, error) {
+			return c.Nimble.GetIssues(context.Background())
+		}, 1},
+		{"Envelope.Issues", func() ([]Issue, error) {
+			envelope, err := c.Wellness.GetEnvelope(context.Background(), "issues")
+			if err != nil {
+				return nil, err
+			}
+			return envelope.Issues()
+		}, 2},
+	}
+	for _, tt := range typed {
+		calls = 0
+		issues, err := tt.get()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if calls != tt.calls || len(issues) != 1 || issues[0].Status.Occurrences != 3 {
+			t.Errorf("%s: expected %d calls of the custom unmarshaler, got %d for %+v", tt.name, tt.calls, calls, issues)
+		}
+	}
+}
+
+func TestContentTypeError(t *testing.T) {
+	tests := []struct {
+		contentType string
+		valid       bool
+	}{
+		{"application/json", true},
+		{"application/json; charset=utf-8", true},
+		{"application/vnd.hpe.infosight.v2+json", true},
+		{"text/html; charset=utf-8", false},
+		{"text/csv", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.contentType, func(t *testing.T) {
+			ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				fmt.Fprint(w, `{"data":[]}`)
+			})
+			c, err := NewClient(ts.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = c.Wellness.GetObjectSetContext(context.Background(), "issues")
+			var contentTypeErr *ContentTypeError
+			if tt.valid {
+				if err != nil {
+					t.Errorf("expected %s to be accepted, got %v", tt.contentType, err)
+				}
+				return
+			}
+			if !errors.As(err, &contentTypeErr) || contentTypeErr.ContentType != tt.contentType || contentTypeErr.StatusCode != http.StatusOK {
+				t.Errorf("expected a ContentTypeError, got %v", err)
+			}
+		})
+	}
+}
+
+func TestWithFields(t *testing.T) {
+	var rawQuery string
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		rawQuery = r.URL.RawQuery
+		issuesHandler(`[]`)(w, r)
+	})
+	c, err := NewClient(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Wellness.GetObjectSetContext(context.Background(), "issues", WithFields("uuid", "condition.severity")); err != nil {
+		t.Fatal(err)
+	}
+	expected := "domain=urn%3Animble&fields=uuid%2Ccondition.severity"
+	if rawQuery != expected {
+		t.Errorf("expected query %q, got %q", expected, rawQuery)
+	}
+
+	for _, fields := range [][]string{nil, {""}, {"uuid", " "}, {"uuid,asset"}} {
+		if _, err := c.Wellness.GetObjectSetContext(context.Background(), "issues", WithFields(fields...)); err == nil {
+			t.Errorf("expected an error for fields %q", fields)
+		}
+	}
+}
+
+func TestWithCallUserAgent(t *testing.T) {
+	var agents []string
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		agents = append(agents, r.Header.Get("User-Agent"))
+		issuesHandler(`[]`)(w, r)
+	})
+	c, err := NewClient(ts.URL, WithUserAgent("dashboard"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, opts := range [][]RequestOption{{WithCallUserAgent("nightly-export")}, nil} {
+		if _, err := c.Wellness.GetObjectSetContext(context.Background(), "issues", opts...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected := []string{"nightly-export", "dashboard"}
+	if !reflect.DeepEqual(agents, expected) {
+		t.Errorf("expected user agents %q, got %q", expected, agents)
+	}
+
+	if _, err := c.Wellness.GetObjectSetContext(context.Background(), "issues", WithCallUserAgent(" ")); err == nil {
+		t.Error("expected error for an empty user agent")
+	}
+}
+
+func TestGetObjectSetPaginated(t *testing.T) {
+	ts := newTestServer(t, pagedHandler(5))
+	c, err := NewClient(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, next, err := c.Wellness.GetObjectSetPaginated(context.Background(), "issues", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first.Data) != 2 {
+		t.Fatalf("expected 2 objects on the first page, got %d", len(first.Data))
+	}
+
+	for i, expected := range []struct {
+		objects int
+		hasMore bool
+	}{{2, true}, {1, false}} {
+		page, hasMore, err := next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hasMore != expected.hasMore || len(page.Data) != expected.objects {
+			t.Fatalf("page %d: expected %d objects (hasMore=%v), got %d (hasMore=%v)", i+2, expected.objects, expected.hasMore, len(page.Data), hasMore)
+		}
+		if page.Request.Paging.Skip != 2*(i+1) {
+			t.Errorf("page %d: unexpected skip %d", i+2, page.Request.Paging.Skip)
+		}
+	}
+
+	if _, hasMore, err := next(); hasMore || err != nil {
+		t.Errorf("expected the object set to be exhausted, got hasMore=%v err=%v", hasMore, err)
+	}
+}
+
+func TestResumeObjectSet(t *testing.T) {
+	ts := newTestServer(t, pagedHandler(250))
+	c, err := NewClient(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	it := c.Wellness.IterateObjectSet(context.Background(), "issues", 100)
+	if !it.Next() {
+		t.Fatal(it.Err())
+	}
+	offset := it.Offset()
+	if offset != 100 {
+		t.Fatalf("expected offset 100 after the first page, got %d", offset)
+	}
+
+	// resume as if the export was interrupted after the first page
+	resumed := c.Wellness.ResumeObjectSet(context.Background(), "issues", 100, offset)
+	var ids []interface{}
+	for resumed.Next() {
+		for _, item := range resumed.Page().Data {
+			ids = append(ids, item.(map[string]interface{})["_id"])
+		}
+	}
+	if err := resumed.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 150 || ids[0] != fmt.Sprintf("%024x", 100) || resumed.Offset() != 250 {
+		t.Errorf("expected the remaining 150 objects, got %d (offset %d)", len(ids), resumed.Offset())
+	}
+}
+
+func TestIteratorError(t *testing.T) {
+	tests := []struct {
+		name  string
+		fail  http.HandlerFunc
+		close bool
+		kind  IteratorErrorKind
+	}{
+		{name: "fault", kind: IteratorErrorFault, fail: func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, `{"fault":{"faultstring":"internal error"}}`)
+		}},
+		{name: "decode", kind: IteratorErrorDecode, fail: func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"data":[`)
+		}},
+		{name: "network", kind: IteratorErrorNetwork, close: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paged := pagedHandler(250)
+			ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Query().Get("skip") != "0" && tt.fail != nil {
+					tt.fail(w, r)
+					return
+				}
+				paged(w, r)
+			})
+			c, err := NewClient(ts.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			it := c.Wellness.IterateObjectSet(context.Background(), "issues", 100)
+			if !it.Next() {
+				t.Fatal(it.Err())
+			}
+			if tt.close {
+				ts.Close()
+			}
+			if it.Next() {
+				t.Fatal("expected the second page to fail")
+			}
+			var iterErr *IteratorError
+			if !errors.As(it.Err(), &iterErr) {
+				t.Fatalf("expected an IteratorError, got %T: %v", it.Err(), it.Err())
+			}
+			if iterErr.Offset != 100 || iterErr.Kind != tt.kind {
+				t.Errorf("expected a %s error at offset 100, got a %s error at offset %d", tt.kind, iterErr.Kind, iterErr.Offset)
+			}
+		})
+	}
+}
+
+func TestIteratorBodyPagination(t *testing.T) {
+	var skips []string
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		skip := r.URL.Query().Get("skip")
+		skips = append(skips, skip)
+		w.Header().Set("Content-Type", "application/json")
+		// pages of varying size, the server drives the pagination through the body
+		switch skip {
+		case "0":
+			fmt.Fprint(w, `{"data":[{"_id":"a"},{"_id":"b"}],"nextOffset":10,"hasMore":true}`)
+		case "10":
+			fmt.Fprint(w, `{"data":[{"_id":"c"}],"nextOffset":25,"hasMore":true}`)
+		default:
+			fmt.Fprint(w, `{"data":[{"_id":"d"},{"_id":"e"},{"_id":"f"}],"hasMore":false}`)
+		}
+	})
+	c, err := NewClient(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	it := c.Wellness.IterateObjectSet(context.Background(), "issues", 3)
+	var ids []interface{}
+	for it.Next() {
+		for _, item := range it.Page().Data {
+			ids = append(ids, item.(map[string]interface{})["_id"])
+		}
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(skips, []string{"0", "10", "25"}) {
+		t.Errorf("expected the offsets of the body to be requested, got %v", skips)
+	}
+	if len(ids) != 6 {
+		t.Errorf("expected 6 objects, got %v", ids)
+	}
+}
+
+func TestWithDedupeKey(t *testing.T) {
+	pages := map[string]string{
+		"0": `[{"_id":"a"},{"_id":"b"},{"_id":"c"}]`,
+		// an issue was raised during the iteration, c moved to the second page
+		"3": `[{"_id":"c"},{"_id":"d"},{"_id":"e"}]`,
+		"6": `[{"_id":"e"},{"title":"without id"}]`,
+	}
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		issuesHandler(pages[r.URL.Query().Get("skip")])(w, r)
+	})
+	c, err := NewClient(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	collect := func(opts ...RequestOption) []interface{} {
+		t.Helper()
+		it := c.Wellness.IterateObjectSet(context.Background(), "issues", 3, opts...)
+		var ids []interface{}
+		for it.Next() {
+			for _, item := range it.Page().Data {
+				ids = append(ids, item.(map[string]interface{})["_id"])
+			}
+		}
+		if err := it.Err(); err != nil {
+			t.Fatal(err)
+		}
+		return ids
+	}
+	if ids := collect(); len(ids) != 8 {
+		t.Errorf("expected 8 objects including duplicates without dedupe key, got %v", ids)
+	}
+	if ids := collect(WithDedupeKey("_id")); !reflect.DeepEqual(ids, []interface{}{"a", "b", "c", "d", "e", nil}) {
+		t.Errorf("expected the duplicates to be skipped, got %v", ids)
+	}
+
+	// the set forgets the oldest keys beyond its bound
+	d := &dedupeSet{key: "_id", max: 2, seen: map[string]bool{}}
+	item := func(id string) interface{} { return map[string]interface{}{"_id": id} }
+	d.filter([]interface{}{item("a"), item("b"), item("c")})
+	if unique := d.filter([]interface{}{item("a"), item("c")}); len(unique) != 1 {
+		t.Errorf("expected only the forgotten key to be returned again, got %v", unique)
+	}
+}
+
+func TestIterateObjectSetClampedLimit(t *testing.T) {
+	var limits []string
+	paged := pagedHandler(250)
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		limits = append(limits, r.URL.Query().Get("limit"))
+		query := r.URL.Query()
+		if limit, _ := strconv.Atoi(query.Get("limit")); limit > 100 {
+			query.Set("limit", "100")
+			r.URL.RawQuery = query.Encode()
+		}
+		paged(w, r)
+	})
+	c, err := NewClient(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	it := c.Wellness.IterateObjectSet(context.Background(), "issues", 500)
+	ids := map[interface{}]bool{}
+	for it.Next() {
+		for _, item := range it.Page().Data {
+			ids[item.(map[string]interface{})["_id"]] = true
+		}
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 250 {
+		t.Errorf("expected 250 distinct objects, got %d", len(ids))
+	}
+	if !reflect.DeepEqual(limits, []string{"500", "100", "100"}) {
+		t.Errorf("unexpected requested limits %v", limits)
+	}
+}
+
+func TestWithMaxPageSize(t *testing.T) {
+	var limits []string
+	paged := pagedHandler(250)
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		limits = append(limits, r.URL.Query().Get("limit"))
+		paged(w, r)
+	})
+	c, err := NewClient(ts.URL, WithMaxPageSize(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logs := captureLog(t)
+	if _, err := c.Wellness.GetObjectSetContext(context.Background(), "issues", WithPaging(0, 1000)); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(logs.String(), "[WARN] limit 1000 of issues exceeds the maximum page size, requesting 100 objects") {
+		t.Errorf("expected the clamped limit to be logged, got %q", logs.String())
+	}
+
+	logs.Reset()
+	if u, err := c.Wellness.ObjectSetURL("issues", WithPaging(0, 1000)); err != nil || !strings.Contains(u, "limit=100") || logs.Len() != 0 {
+		t.Errorf("expected the clamped url without a warning, got %q (%v, %q)", u, err, logs.String())
+	}
+
+	logs.Reset()
+	it := c.Wellness.IterateObjectSet(context.Background(), "issues", 500)
+	n := 0
+	for it.Next() {
+		n += len(it.Page().Data)
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if n != 250 || logs.Len() != 0 {
+		t.Errorf("expected the iterator to fetch 250 objects in pages of the maximum size, got %d (%q)", n, logs.String())
+	}
+	if !reflect.DeepEqual(limits, []string{"100", "100", "100", "100"}) {
+		t.Errorf("unexpected requested limits %v", limits)
+	}
+}
+
+// largeFixture returns a response with n issues
+func largeFixture(b *testing.B, n int) []byte {
+	var issues []interface{}
+	if err := json.Unmarshal([]byte(issuesFixture), &issues); err != nil {
+		b.Fatal(err)
+	}
+	data := make([]interface{}, 0, n)
+	for i := 0; i < n; i++ {
+		data = append(data, issues[i%len(issues)])
+	}
+	raw, err := json.Marshal(map[string]interface{}{"data": data, "status": map[string]string{"message": "success"}})
+	if err != nil {
+		b.Fatal(err)
+	}
+	return raw
+}
+
+// BenchmarkDecodeStream measures the streaming decoder used before pooling
+func BenchmarkDecodeStream(b *testing.B) {
+	raw := largeFixture(b, 500)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var apiResponse APIResponse
+		if err := json.NewDecoder(bytes.NewReader(raw)).Decode(&apiResponse); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkDecodePooled measures decodeJSON
+func BenchmarkDecodePooled(b *testing.B) {
+	raw := largeFixture(b, 500)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var apiResponse APIResponse
+		if err := decodeJSON(bytes.NewReader(raw), &apiResponse, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkDecodeSmall compares the pooled buffer of decodeJSON with reading small responses directly,
+// the pooled buffer is as fast for a handful of issues so there is no separate path for small bodies
+func BenchmarkDecodeSmall(b *testing.B) {
+	raw := largeFixture(b, 2)
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var apiResponse APIResponse
+			if err := decodeJSON(ioutil.NopCloser(bytes.NewReader(raw)), &apiResponse, nil); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("direct", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var apiResponse APIResponse
+			body, err := ioutil.ReadAll(ioutil.NopCloser(bytes.NewReader(raw)))
+			if err != nil {
+				b.Fatal(err)
+			}
+			if err := json.Unmarshal(body, &apiResponse); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkDecodeUnmarshaler measures how much of typed decoding is done by the unmarshaler passed to
+// WithJSONUnmarshaler, reported as unmarshal-bytes/op by a counting stub. GetPage decodes the response in a single
+// pass, the iterator based methods decode it into an APIResponse first and the re-marshaled data again.
+func BenchmarkDecodeUnmarshaler(b *testing.B) {
+	raw := largeFixture(b, 500)
+	var unmarshaled int
+	counting := func(data []byte, v interface{}) error {
+		unmarshaled += len(data)
+		return json.Unmarshal(data, v)
+	}
+	b.Run("single-pass", func(b *testing.B) {
+		unmarshaled = 0
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var r typedResponse[Issue]
+			if err := decodeJSON(bytes.NewReader(raw), &r, counting); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.ReportMetric(float64(unmarshaled)/float64(b.N), "unmarshal-bytes/op")
+	})
+	b.Run("two-pass", func(b *testing.B) {
+		unmarshaled = 0
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var r APIResponse
+			if err := decodeJSON(bytes.NewReader(raw), &r, counting); err != nil {
+				b.Fatal(err)
+			}
+			if _, err := decodeData[Issue](&r, counting); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.ReportMetric(float64(unmarshaled)/float64(b.N), "unmarshal-bytes/op")
+	})
+}
+
+func TestSetDefaults(t *testing.T) {
+	var queries []url.Values
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.Query())
+		pagedHandler(150)(w, r)
+	})
+	c, err := NewClient(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Wellness.SetDefaults("issues", WithPaging(0, 100), WithSort("condition.severity asc"))
+
+	ctx := context.Background()
+	if _, err := c.Wellness.GetObjectSetContext(ctx, "issues"); err != nil {
+		t.Fatal(err)
+	}
+	if q := queries[0]; q.Get("limit") != "100" || q.Get("sort") != "condition.severity asc" {
+		t.Errorf("expected the defaults to be applied, got %v", q)
+	}
+
+	if _, err := c.Wellness.GetObjectSetContext(ctx, "issues", WithPaging(0, 10)); err != nil {
+		t.Fatal(err)
+	}
+	if q := queries[1]; q.Get("limit") != "10" || q.Get("sort") != "condition.severity asc" {
+		t.Errorf("expected the paging to be overridden, got %v", q)
+	}
+
+	if _, err := c.Wellness.GetObjectSetContext(ctx, "issue/5d9eb55a28c7eb0001f472eb"); err != nil {
+		t.Fatal(err)
+	}
+	if q := queries[2]; q.Get("limit") != "" || q.Get("sort") != "" {
+		t.Errorf("expected no defaults for other object sets, got %v", q)
+	}
+
+	// the iterator picks up the default page size
+	queries = nil
+	it := c.Wellness.IterateObjectSet(ctx, "issues", 0)
+	for it.Next() {
+	}
+	if it.Err() != nil || len(queries) != 2 || queries[1].Get("skip") != "100" {
+		t.Errorf("expected two pages of 100 objects, got %v (%v)", queries, it.Err())
+	}
+
+	c.Wellness.SetDefaults("issues")
+	queries = nil
+	if _, err := c.Wellness.GetObjectSetContext(ctx, "issues"); err != nil {
+		t.Fatal(err)
+	}
+	if q := queries[0]; q.Get("limit") != "" {
+		t.Errorf("expected the defaults to be removed, got %v", q)
+	}
+}
+
+func TestSetDefaultFields(t *testing.T) {
+	var queries []url.Values
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.Query())
+		issuesHandler(issuesFixture)(w, r)
+	})
+	c, err := NewClient(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Wellness.SetDefaultFields("issues", "_id", "condition.severity", "status.value"); err != nil {
+		t.Fatal(err)
+	}
+	c.Wellness.SetDefaults("issues", WithSort("condition.severity asc"))
+
+	ctx := context.Background()
+	if _, err := c.Wellness.GetObjectSetContext(ctx, "issues"); err != nil {
+		t.Fatal(err)
+	}
+	if q := queries[0]; q.Get("fields") != "_id,condition.severity,status.value" || q.Get("sort") != "condition.severity asc" {
+		t.Errorf("expected the default fields and defaults to be sent, got %v", q)
+	}
+
+	if _, err := c.Wellness.GetObjectSetContext(ctx, "issues", WithFields("uuid")); err != nil {
+		t.Fatal(err)
+	}
+	if q := queries[1]; q.Get("fields") != "uuid" {
+		t.Errorf("expected the fields of the call, got %v", q)
+	}
+
+	clone, err := c.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := clone.Wellness.GetObjectSetContext(ctx, "issues"); err != nil {
+		t.Fatal(err)
+	}
+	if q := queries[2]; q.Get("fields") != "_id,condition.severity,status.value" {
+		t.Errorf("expected the clone to keep the default fields, got %v", q)
+	}
+
+	if err := c.Wellness.SetDefaultFields("issues"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Wellness.GetObjectSetContext(ctx, "issues"); err != nil {
+		t.Fatal(err)
+	}
+	if q := queries[3]; q.Get("fields") != "" || q.Get("sort") != "condition.severity asc" {
+		t.Errorf("expected the default fields to be removed, got %v", q)
+	}
+
+	if err := c.Wellness.SetDefaultFields("issues", "uuid", "a,b"); err == nil {
+		t.Error("expected error for an invalid field name")
+	}
+}
+
+func TestDefaultPaging(t *testing.T) {
+	var queries []url.Values
+	ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.Query())
+		issuesHandler(`[]`)(w, r)
+	})
+	c, err := NewClient(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.Wellness.DefaultPaging = PagingInfo{Limit: 100}
+	for _, opts := range [][]RequestOption{nil, {WithPaging(10, 20)}} {
+		if _, err := c.Wellness.GetObjectSetContext(context.Background(), "issues", opts...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c.Wellness.DefaultPaging = PagingInfo{}
+	if _, err := c.Wellness.GetObjectSetContext(context.Background(), "issues"); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := [][2]string{{"", "100"}, {"10", "20"}, {"", ""}}
+	for i, query := range queries {
+		if query.Get("skip") != expected[i][0] || query.Get("limit") != expected[i][1] {
+			t.Errorf("request %d: expected skip=%q limit=%q, got %s", i, expected[i][0], expected[i][1], query.Encode())
+		}
+	}
+}
+
+func TestDiffObjectSet(t *testing.T) {
+	ts := newTestServer(t, issuesHandler(`[
+		{"_id": "1", "asset": {"urn": "urn:nimble:array:A"}, "status": {"value": "open"}},
+		{"_id": "2", "asset": {"urn": "urn:nimble:array:B"}, "status": {"value": "resolved"}},
+		{"_id": "4", "asset": {"urn": "urn:nimble:array:D"}, "status": {"value": "new"}}
+	]`))
+	c, err := NewClient(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var previous APIResponse
+	err = json.Unmarshal([]byte(`{"data": [
+		{"_id": "1", "asset": {"urn": "urn:nimble:array:A"}, "status": {"value": "open"}},
+		{"_id": "2", "asset": {"urn": "urn:nimble:array:B"}, "status": {"value": "open"}},
+		{"_id": "3", "asset": {"urn": "urn:nimble:array:C"}, "status": {"value": "open"}}
+	]}`), &previous)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := func(objects []interface{}) []string {
+		result := []string{}
+		for _, obj := range objects {
+			result = append(result, obj.(map[string]interface{})["_id"].(string))
+		}
+		return result
+	}
+
+	added, removed, changed, err := c.Wellness.DiffObjectSet(context.Background(), "issues", previous)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(ids(added), ids(removed), ids(changed)) != "[4] [3] [2]" {
+		t.Errorf("unexpected diff added=%v removed=%v changed=%v", ids(added), ids(removed), ids(changed))
+	}
+	if changed[0].(map[string]interface{})["status"].(map[string]interface{})["value"] != "resolved" {
+		t.Errorf("expected the current version of changed objects, got %v", changed[0])
+	}
+
+	c.Wellness.DiffKey = "asset.urn"
+	added, removed, _, err = c.Wellness.DiffObjectSet(context.Background(), "issues", previous)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(added) != 1 || len(removed) != 1 {
+		t.Errorf("unexpected diff by asset added=%v removed=%v", added, removed)
+	}
+
+	c.Wellness.DiffKey = "uuid"
+	if _, _, _, err := c.Wellness.DiffObjectSet(context.Background(), "issues", previous); err == nil {
+		t.Error("expected an error for objects without key")
+	}
+}
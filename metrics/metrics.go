@@ -0,0 +1,89 @@
+// Package metrics exports Prometheus metrics of infosight clients. It is a separate package so that
+// only users of the metrics depend on the Prometheus client.
+package metrics
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/autonubil/go-infosight/infosight"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// observer records the requests and token refreshes of clients in Prometheus collectors
+type observer struct {
+	requests       *prometheus.CounterVec
+	duration       *prometheus.HistogramVec
+	tokenRefreshes *prometheus.CounterVec
+}
+
+// WithPrometheus registers the collectors infosight_requests_total, infosight_request_duration_seconds and
+// infosight_token_refresh_total with registerer and records the requests and token refreshes of the client.
+// Several clients can use the same registerer, they share the collectors.
+func WithPrometheus(registerer prometheus.Registerer) infosight.ClientOption {
+	return func(c *infosight.Client) error {
+		if registerer == nil {
+			return errors.New("nil prometheus registerer")
+		}
+		o := &observer{
+			requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+				Name: "infosight_requests_total",
+				Help: "Number of requests sent to InfoSight by method and status code (error if no response was received).",
+			}, []string{"method", "code"}),
+			duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+				Name:    "infosight_request_duration_seconds",
+				Help:    "Duration of requests to InfoSight until the response headers were received, including retries.",
+				Buckets: prometheus.DefBuckets,
+			}, []string{"method"}),
+			tokenRefreshes: prometheus.NewCounterVec(prometheus.CounterOpts{
+				Name: "infosight_token_refresh_total",
+				Help: "Number of access token requests by result.",
+			}, []string{"result"}),
+		}
+		var err error
+		if o.requests, err = register(registerer, o.requests); err != nil {
+			return err
+		}
+		if o.duration, err = register(registerer, o.duration); err != nil {
+			return err
+		}
+		if o.tokenRefreshes, err = register(registerer, o.tokenRefreshes); err != nil {
+			return err
+		}
+		return infosight.WithObserver(o)(c)
+	}
+}
+
+// register registers collector or returns the collector registered before, e.g. by another client
+func register[T prometheus.Collector](registerer prometheus.Registerer, collector T) (T, error) {
+	if err := registerer.Register(collector); err != nil {
+		var registered prometheus.AlreadyRegisteredError
+		if errors.As(err, &registered) {
+			if existing, ok := registered.ExistingCollector.(T); ok {
+				return existing, nil
+			}
+		}
+		return collector, err
+	}
+	return collector, nil
+}
+
+// ObserveRequest counts the request and records its duration
+func (o *observer) ObserveRequest(method string, status int, duration time.Duration, err error) {
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(status)
+	}
+	o.requests.WithLabelValues(method, code).Inc()
+	o.duration.WithLabelValues(method).Observe(duration.Seconds())
+}
+
+// ObserveTokenRefresh counts the token request
+func (o *observer) ObserveTokenRefresh(err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	o.tokenRefreshes.WithLabelValues(result).Inc()
+}
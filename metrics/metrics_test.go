@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/autonubil/go-infosight/infosight"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestWithPrometheus(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/oauth/token" {
+			fmt.Fprint(w, `{"access_token":"token","token_type":"BearerToken","expires_in":3600}`)
+			return
+		}
+		if r.URL.Query().Get("domain") == "urn:missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		fmt.Fprint(w, `{"data":[]}`)
+	}))
+	defer ts.Close()
+
+	registry := prometheus.NewRegistry()
+	c, err := infosight.NewClient(ts.URL, WithPrometheus(registry))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a second client shares the collectors
+	missing, err := infosight.NewClient(ts.URL, WithPrometheus(registry), infosight.WithDomain("urn:missing"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := c.Wellness.GetIssues(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := missing.Wellness.GetIssues(); err == nil {
+		t.Fatal("expected a fault")
+	}
+
+	expected := `
+# HELP infosight_requests_total Number of requests sent to InfoSight by method and status code (error if no response was received).
+# TYPE infosight_requests_total counter
+infosight_requests_total{code="200",method="GET"} 2
+infosight_requests_total{code="404",method="GET"} 1
+# HELP infosight_token_refresh_total Number of access token requests by result.
+# TYPE infosight_token_refresh_total counter
+infosight_token_refresh_total{result="success"} 2
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "infosight_requests_total", "infosight_token_refresh_total"); err != nil {
+		t.Error(err)
+	}
+	if n := testutil.CollectAndCount(registry, "infosight_request_duration_seconds"); n != 1 {
+		t.Errorf("expected a duration histogram, got %d", n)
+	}
+}